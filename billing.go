@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"timo/reconcile"
+)
+
+// BillingLine adds up the Kimai entries of one customer and project, times
+// are in minutes
+type BillingLine struct {
+	Customer    string  `json:"customer"`
+	Project     string  `json:"project"`
+	Entries     int     `json:"entries"`
+	Billable    int     `json:"billable"`
+	NonBillable int     `json:"non_billable"`
+	Amount      float64 `json:"amount"` // of the billable entries only
+}
+
+// BillingReport is what is billed per customer and project in one month
+type BillingReport struct {
+	Month string        `json:"month"` // YYYY-MM
+	Lines []BillingLine `json:"lines"`
+	Total BillingLine   `json:"total"`
+}
+
+// returns the amount billed for an entry, from its hourly rate when Kimai
+// shows no amount
+func entryAmount(entry KimaiMonthlyData) float64 {
+	if entry.Amount != 0 {
+		return entry.Amount
+	}
+	return entry.HourlyRate * float64(entry.WorkedMinutes) / 60
+}
+
+// adds up the entries of a month per customer and project. Only work
+// entries marked billable in Kimai are billed, breaks and absences never are
+// whatever Kimai says.
+func buildBillingReport(month string, entries []KimaiMonthlyData, rules reconcile.Rules) BillingReport {
+	report := BillingReport{Month: month, Total: BillingLine{Customer: "total"}}
+	lines := make(map[string]*BillingLine)
+	for _, entry := range entries {
+		if kimaiMonthKey(entry) != month {
+			continue
+		}
+		class := rules.Classify(reconcileEntry(entry))
+		if class == reconcile.ClassIgnore {
+			continue
+		}
+		customer, project := strings.TrimSpace(entry.Customer), strings.TrimSpace(entry.Project)
+		key := strings.ToLower(customer + "|" + project)
+		line, found := lines[key]
+		if !found {
+			line = &BillingLine{Customer: customer, Project: project}
+			lines[key] = line
+		}
+
+		line.Entries++
+		if entry.Billable && class == reconcile.ClassWork {
+			line.Billable += entry.WorkedMinutes
+			line.Amount += entryAmount(entry)
+		} else {
+			line.NonBillable += entry.WorkedMinutes
+		}
+	}
+
+	for _, line := range lines {
+		report.Lines = append(report.Lines, *line)
+		report.Total.Entries += line.Entries
+		report.Total.Billable += line.Billable
+		report.Total.NonBillable += line.NonBillable
+		report.Total.Amount += line.Amount
+	}
+	sort.Slice(report.Lines, func(i, j int) bool {
+		if report.Lines[i].Customer != report.Lines[j].Customer {
+			return strings.ToLower(report.Lines[i].Customer) < strings.ToLower(report.Lines[j].Customer)
+		}
+		return strings.ToLower(report.Lines[i].Project) < strings.ToLower(report.Lines[j].Project)
+	})
+	return report
+}
+
+// returns the billing report as text, in the style of the monthly summary
+func formatBillingReport(report BillingReport) string {
+	var result strings.Builder
+
+	monthName := report.Month
+	if t, err := time.Parse("2006-01", report.Month); err == nil {
+		monthName = t.Format("January 2006")
+	}
+	result.WriteString(fmt.Sprintf("💶 Billing %s\n\n", monthName))
+
+	if len(report.Lines) == 0 {
+		result.WriteString(italicStyle.Render(" no Kimai entries in this month") + "\n")
+		return result.String()
+	}
+
+	result.WriteString(" Customer / Project             | Billable | Not billed | Amount\n")
+	result.WriteString("------------------------------------------------------------------\n")
+	for _, line := range report.Lines {
+		name := line.Customer + " / " + line.Project
+		if len([]rune(name)) > 30 {
+			name = string([]rune(name)[:29]) + "…"
+		}
+		result.WriteString(fmt.Sprintf(" %-30s | %-8s | %-10s | %9.2f\n",
+			name, formatMinutes(line.Billable, false), formatMinutes(line.NonBillable, false), line.Amount))
+	}
+	result.WriteString("------------------------------------------------------------------\n")
+	result.WriteString(fmt.Sprintf(" %-30s   %-8s   %-10s   %9.2f\n",
+		fmt.Sprintf("%d entries", report.Total.Entries),
+		formatMinutes(report.Total.Billable, false), formatMinutes(report.Total.NonBillable, false), report.Total.Amount))
+	return result.String()
+}
+
+// prints what is billed per customer and project in a month, current month
+// by default, as text, CSV or JSON with all times in minutes
+func runBilling(opts cliOptions, out io.Writer) error {
+	if opts.month == "" {
+		opts.month = time.Now().Format("2006-01")
+	}
+	report := buildBillingReport(opts.month, loadTimesheetEntries(opts.month), reconcileRules)
+
+	switch opts.format {
+	case "", "text":
+		fmt.Fprint(out, formatBillingReport(report))
+		return nil
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case "csv":
+		w := csv.NewWriter(out)
+		w.Write([]string{"customer", "project", "entries", "billable", "non_billable", "amount"})
+		for _, line := range append(report.Lines, report.Total) {
+			w.Write([]string{line.Customer, line.Project, strconv.Itoa(line.Entries),
+				strconv.Itoa(line.Billable), strconv.Itoa(line.NonBillable), strconv.FormatFloat(line.Amount, 'f', 2, 64)})
+		}
+		w.Flush()
+		return w.Error()
+	}
+	return fmt.Errorf("unknown billing format '%s' (use text, csv or json)", opts.format)
+}
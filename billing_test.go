@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"timo/reconcile"
+)
+
+// TestBillingReport tests buildBillingReport with billable, non billable
+// and break entries
+func TestBillingReport(t *testing.T) {
+	entries := []KimaiMonthlyData{
+		{ID: 1, Date: "2025-03-03", WorkedMinutes: 270, Customer: "ACME", Project: "Timo", Activity: "Development", Billable: true, HourlyRate: 50, Amount: 225},
+		{ID: 2, Date: "2025-03-03", WorkedMinutes: 45, Customer: "ACME", Project: "Break", Activity: "Lunch", Billable: true},
+		{ID: 3, Date: "2025-03-04", WorkedMinutes: 120, Customer: "acme", Project: "timo", Activity: "Review", Billable: true, HourlyRate: 50},
+		{ID: 4, Date: "2025-03-04", WorkedMinutes: 60, Customer: "ACME", Project: "Timo", Activity: "Meeting", Billable: false, HourlyRate: 50},
+		{ID: 5, Date: "2025-03-05", WorkedMinutes: 480, Customer: "Internal", Project: "Internal", Activity: "Public Holiday", Billable: true},
+		{ID: 6, Date: "2025-04-01", WorkedMinutes: 480, Customer: "ACME", Project: "Timo", Activity: "Development", Billable: true, HourlyRate: 50},
+	}
+	report := buildBillingReport("2025-03", entries, reconcile.DefaultRules())
+
+	// lines are sorted by customer and project, April is left out
+	if len(report.Lines) != 3 {
+		t.Fatalf("got %d lines, expected 3: %+v", len(report.Lines), report.Lines)
+	}
+
+	lunch := report.Lines[0]
+	if lunch.Customer != "ACME" || lunch.Project != "Break" {
+		t.Errorf("got first line %s / %s, expected ACME / Break", lunch.Customer, lunch.Project)
+	}
+	if lunch.Billable != 0 {
+		t.Errorf("got %d break minutes billed, expected 0", lunch.Billable)
+	}
+
+	timo := report.Lines[1]
+	if timo.Entries != 3 {
+		t.Errorf("got %d entries for ACME / Timo, expected 3 with the project names ignoring case", timo.Entries)
+	}
+	if timo.Billable != 390 || timo.NonBillable != 60 {
+		t.Errorf("got %d billable and %d non billable minutes, expected 390 and 60", timo.Billable, timo.NonBillable)
+	}
+	if timo.Amount != 325 {
+		t.Errorf("got amount %v, expected 325 with the review billed from its rate", timo.Amount)
+	}
+
+	if holiday := report.Lines[2]; holiday.NonBillable != 480 {
+		t.Errorf("got %d non billable absence minutes, expected 480", holiday.NonBillable)
+	}
+	if report.Total.Entries != 5 || report.Total.Amount != 325 {
+		t.Errorf("got total of %d entries and %v, expected 5 and 325", report.Total.Entries, report.Total.Amount)
+	}
+}
+
+// TestParseAmount tests parseAmount with decimal and thousands separators
+func TestParseAmount(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected float64
+		warnings int
+	}{
+		{"", 0, 0},
+		{"45,00 €", 45, 0},
+		{"1,234.50", 1234.5, 0},
+		{"1.234,50 €", 1234.5, 0},
+		{"1.234", 1234, 0},
+		{"1,500", 1500, 0},
+		{"1.234.567", 1234567, 0},
+		{"-1.500", -1500, 0},
+		{"0.125", 0.125, 1},
+		{",125", 0.125, 1},
+		{"12,5", 12.5, 0},
+		{"n/a", 0, 0},
+		{"1-2", 0, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			var issues ParseDiagnostics
+			got := parseAmount(&issues, "2025-03-03", "amount", tc.value)
+			if got != tc.expected {
+				t.Errorf("got %v, expected %v", got, tc.expected)
+			}
+			if len(issues) != tc.warnings {
+				t.Errorf("got %d warnings, expected %d: %v", len(issues), tc.warnings, issues)
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"timo/reconcile"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// options of the headless commands, read from the command line
+type cliOptions struct {
+	fetchRange FetchRange
+	month      string // YYYY-MM, report and export
+	format     string // text, csv or json
+	source     string // name of one source, export only
+	kimaiID    string
+	action     string // list, add or remove, vault only
+
+	passwordStdin bool // read the passwords from stdin, fetch only
+	punches       bool // list the Timenet punches below each day, text report only
+}
+
+// headless commands, run instead of the UI: timo fetch, timo report...
+var cliCommands = map[string]func(opts cliOptions, out io.Writer) error{
+	"fetch":  runFetch,
+	"report": runReport,
+	"export": runExport,
+	"diff": func(opts cliOptions, out io.Writer) error {
+		fmt.Fprint(out, BuildDiff())
+		return nil
+	},
+	"vault":   runVault,
+	"billing": runBilling,
+}
+
+// runs a headless command and returns the process exit code
+func runCommand(name string, opts cliOptions) int {
+	command, found := cliCommands[name]
+	if !found {
+		fmt.Fprintf(os.Stderr, "unknown command '%s' (use fetch, report, export, diff, vault or billing)\n", name)
+		return 2
+	}
+	if err := command(opts, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// fetches all sources one after the other, fails if any source fails.
+// Credentials missing from flags, stdin, environment and credential helper
+// come from the vault when there is one, else they are asked.
+func runFetch(opts cliOptions, out io.Writer) error {
+	creds, err := unattendedCredentials(opts, os.Stdin)
+	if err != nil {
+		return err
+	}
+	if !creds.complete() {
+		path, err := vaultPath()
+		if err == nil && vaultExists(path) {
+			fromVault, err := unlockVaultProfile(path)
+			if err != nil {
+				return err
+			}
+			creds = creds.fill(fromVault)
+		} else if creds, err = promptCredentials(creds); err != nil {
+			return err
+		}
+	}
+	sources, err := newSources(creds)
+	if err != nil {
+		return err
+	}
+	setupScraper()
+
+	failed := 0
+	for _, src := range sources {
+		start := time.Now()
+		months, diags, err := fetchSource(context.Background(), src, opts.fetchRange)
+		var partial *partialFetchError
+		switch {
+		case errors.As(err, &partial):
+			// the months fetched are stored, the rest must be fetched again
+			fmt.Fprintf(out, "%s: %v\n", src.Name(), partial)
+			failed++
+		case err != nil:
+			fmt.Fprintf(out, "%s fetch failed: %v\n", src.Name(), err)
+			failed++
+		case months == 0:
+			fmt.Fprintf(out, "%s is already up to date\n", src.Name())
+		default:
+			fmt.Fprintf(out, "%s fetch completed successfully (%d months) in %s\n",
+				src.Name(), months, time.Since(start).Round(time.Second))
+		}
+		for _, issue := range diags {
+			fmt.Fprintf(out, "  %s %s\n", issue.Severity, issue)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d sources failed", failed, len(sources))
+	}
+	return nil
+}
+
+// prints the same monthly summary shown in the UI, current month by default,
+// or its reconciliation as JSON or CSV with all times in minutes
+func runReport(opts cliOptions, out io.Writer) error {
+	if opts.month == "" {
+		opts.month = time.Now().Format("2006-01")
+	}
+	switch opts.format {
+	case "", "text":
+		report, err := BuildMonthReport(opts.month, opts.punches)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(out, report)
+		return nil
+	case "json":
+		month, err := BuildMonthResult(opts.month)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(month)
+	case "csv":
+		month, err := BuildMonthResult(opts.month)
+		if err != nil {
+			return err
+		}
+		return writeMonthResultCSV(out, month)
+	}
+	return fmt.Errorf("unknown report format '%s' (use text, csv or json)", opts.format)
+}
+
+// writes one row per day followed by a row with the monthly totals
+func writeMonthResultCSV(out io.Writer, month reconcile.MonthResult) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"date", "day_type", "day_label", "expected", "overtime", "timenet", "kimai", "break", "absence", "diff", "warning", "flags", "punches"})
+	for _, day := range month.Days {
+		w.Write([]string{day.Date, string(day.DayType), day.DayLabel,
+			strconv.Itoa(day.Expected), strconv.Itoa(day.Overtime), strconv.Itoa(day.Worked), strconv.Itoa(day.Kimai),
+			strconv.Itoa(day.Break), strconv.Itoa(day.Absence), strconv.Itoa(day.Diff),
+			strconv.FormatBool(day.Warning), strings.Join(day.Flags, " "), formatPunches(day.Punches)})
+	}
+	w.Write([]string{"total", month.Month, "",
+		strconv.Itoa(month.Expected), strconv.Itoa(month.Overtime), strconv.Itoa(month.Worked), strconv.Itoa(month.Kimai),
+		strconv.Itoa(month.Break), strconv.Itoa(month.Absence), strconv.Itoa(month.Diff), "", "", ""})
+	w.Flush()
+	return w.Error()
+}
+
+// writes the stored data as JSON, all sources unless one is chosen, or as
+// CSV, the attendance source unless another one is chosen
+func runExport(opts cliOptions, out io.Writer) error {
+	var names []string
+	for _, r := range sourceRegistry {
+		if opts.source == "" || sourceKey(r.name) == sourceKey(opts.source) {
+			names = append(names, r.name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("unknown source '%s'", opts.source)
+	}
+
+	switch opts.format {
+	case "", "json":
+		data := make(map[string]any)
+		for _, name := range names {
+			sourceData, err := loadSourceData(name, opts.month)
+			if err != nil {
+				return err
+			}
+			data[sourceKey(name)] = sourceData
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	case "csv":
+		if opts.source == "" {
+			names = registeredSourceNames(AttendanceSource)
+			if len(names) == 0 {
+				return fmt.Errorf("no attendance source registered, choose one with --source")
+			}
+		}
+		sourceData, err := loadSourceData(names[0], opts.month)
+		if err != nil {
+			return err
+		}
+		return writeSourceCSV(out, sourceData)
+	}
+	return fmt.Errorf("unknown export format '%s' (use csv or json)", opts.format)
+}
+
+// loads the stored data of a source, only one month (YYYY-MM) when not blank
+func loadSourceData(name string, month string) (any, error) {
+	for _, r := range sourceRegistry {
+		if r.name != name {
+			continue
+		}
+		if r.kind == TimesheetSource {
+			return history.loadKimai(name, month)
+		}
+		data, err := history.loadTimenet(name)
+		if err != nil || month == "" {
+			return data, err
+		}
+		i := monthIndexOf(data, month)
+		if i < 0 {
+			return nil, fmt.Errorf("month %s not found in %s history", month, name)
+		}
+		data.MonthlyData = data.MonthlyData[i : i+1]
+		return data, nil
+	}
+	return nil, fmt.Errorf("unknown source '%s'", name)
+}
+
+// writes one row per Timenet day or per Kimai entry, times in minutes
+func writeSourceCSV(out io.Writer, data any) error {
+	w := csv.NewWriter(out)
+	switch data := data.(type) {
+	case *TimenetData:
+		w.Write([]string{"date", "day_type", "day_label", "expected", "worked", "overtime", "punches"})
+		// oldest day first, as in the Kimai export
+		for i := len(data.MonthlyData) - 1; i >= 0; i-- {
+			for _, day := range data.MonthlyData[i].DailyData {
+				w.Write([]string{day.Date, string(day.DayType), day.DayLabel,
+					strconv.Itoa(day.ExpectedMinutes), strconv.Itoa(day.WorkedMinutes), strconv.Itoa(day.OvertimeMinutes),
+					formatPunches(day.Punches)})
+			}
+		}
+	case *KimaiData:
+		w.Write([]string{"date", "in", "out", "worked", "customer", "project", "activity", "username",
+			"id", "description", "billable", "hourly_rate", "amount", "url"})
+		for _, entry := range data.MonthlyData {
+			w.Write([]string{entry.Date, entry.In, entry.Out, strconv.Itoa(entry.WorkedMinutes), entry.Customer, entry.Project, entry.Activity, entry.Username,
+				strconv.Itoa(entry.ID), entry.Description, strconv.FormatBool(entry.Billable),
+				strconv.FormatFloat(entry.HourlyRate, 'f', 2, 64), strconv.FormatFloat(entry.Amount, 'f', 2, 64), kimaiEntryURL(entry)})
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// manages the profiles stored in the vault: timo vault list|add|remove
+func runVault(opts cliOptions, out io.Writer) error {
+	path, err := vaultPath()
+	if err != nil {
+		return err
+	}
+	if opts.action != "add" && !vaultExists(path) {
+		return fmt.Errorf("no vault yet, create one with: timo vault add")
+	}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return fmt.Errorf("the vault passphrase can only be typed in a terminal")
+	}
+	passphrase, err := readSecret(os.Stdin.Fd(), "Vault Passphrase: ")
+	if err != nil {
+		return err
+	}
+	if !vaultExists(path) {
+		confirm, err := readSecret(os.Stdin.Fd(), "Repeat Vault Passphrase: ")
+		if err != nil {
+			return err
+		}
+		if confirm != passphrase {
+			return fmt.Errorf("passphrases do not match")
+		}
+	}
+	v, err := openVault(path, passphrase)
+	if err != nil {
+		return err
+	}
+
+	switch opts.action {
+	case "", "list":
+		for _, name := range v.profileNames() {
+			fmt.Fprintf(out, "%s (Kimai ID %s)\n", name, v.Profiles[name].KimaiID)
+		}
+		return nil
+	case "add":
+		creds, err := promptCredentials(Credentials{KimaiID: opts.kimaiID})
+		if err != nil {
+			return err
+		}
+		v.Profiles[vaultProfile] = creds
+		if err := v.save(); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "profile %s saved\n", vaultProfile)
+		return nil
+	case "remove":
+		if _, err := v.profile(vaultProfile); err != nil {
+			return err
+		}
+		delete(v.Profiles, vaultProfile)
+		if err := v.save(); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "profile %s removed\n", vaultProfile)
+		return nil
+	}
+	return fmt.Errorf("unknown vault action '%s' (use list, add or remove)", opts.action)
+}
+
+// asks for the vault passphrase and returns the credentials of the profile
+func unlockVaultProfile(path string) (Credentials, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return Credentials{}, fmt.Errorf("the vault passphrase can only be typed in a terminal")
+	}
+	passphrase, err := readSecret(os.Stdin.Fd(), "Vault Passphrase: ")
+	if err != nil {
+		return Credentials{}, err
+	}
+	v, err := openVault(path, passphrase)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return v.profile(vaultProfile)
+}
+
+// asks on the terminal for the credentials that are still blank
+func promptCredentials(creds Credentials) (Credentials, error) {
+	fd := os.Stdin.Fd()
+	if !term.IsTerminal(fd) {
+		return creds, fmt.Errorf("passwords can only be typed in a terminal, see --password-stdin")
+	}
+	var err error
+	if creds.TimenetPassword == "" {
+		if creds.TimenetPassword, err = readSecret(fd, "Timenet Password: "); err != nil {
+			return creds, err
+		}
+	}
+	if creds.KimaiID == "" {
+		fmt.Fprint(os.Stderr, "Kimai ID: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return creds, err
+		}
+		creds.KimaiID = strings.TrimSpace(line)
+	}
+	if creds.KimaiPassword == "" {
+		if creds.KimaiPassword, err = readSecret(fd, "Kimai Password: "); err != nil {
+			return creds, err
+		}
+	}
+	return creds, nil
+}
+
+// reads a line from the terminal without echoing it
+func readSecret(fd uintptr, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
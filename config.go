@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the content of config.yaml, everything that depends on the
+// Timenet tenant or the Kimai host. Values missing in the file keep their
+// defaults.
+type Config struct {
+	Timenet TimenetConfig `yaml:"timenet"`
+	Kimai   KimaiConfig   `yaml:"kimai"`
+
+	// command printing the credentials as JSON, for unattended runs
+	CredentialHelper string `yaml:"credential_helper"`
+
+	// keep the site cookies between fetches to skip the logins, off unless
+	// set, see session.go
+	KeepSessions bool `yaml:"keep_sessions"`
+}
+
+type TimenetConfig struct {
+	URL       string           `yaml:"url"`
+	TenantID  string           `yaml:"tenant_id"`
+	Timeout   time.Duration    `yaml:"timeout"` // whole scrape, e.g. 35s
+	Selectors TimenetSelectors `yaml:"selectors"`
+
+	// extra day labels of the tenant, tried before the default ones
+	DayTypes []DayTypeLabel `yaml:"day_types"`
+
+	// "dom" reads the rendered checks page, "network" captures the JSON the
+	// page loads from the Timenet backend, see timenet_network.go
+	Mode    string         `yaml:"mode"`
+	Network TimenetNetwork `yaml:"network"`
+}
+
+type TimenetNetwork struct {
+	URLPattern string            `yaml:"url_pattern"` // regex of the backend URLs returning one month
+	Fields     TimenetJSONFields `yaml:"fields"`
+}
+
+// keys of the month JSON, "data.days" reads nested objects. Times may be
+// minutes or text like "8h 30m", dates ISO or DD/MM/YYYY.
+type TimenetJSONFields struct {
+	Days     string `yaml:"days"` // list of days of the month
+	Date     string `yaml:"date"`
+	DayLabel string `yaml:"day_label"`
+	Expected string `yaml:"expected"`
+	Worked   string `yaml:"worked"`
+	Overtime string `yaml:"overtime"`
+	Punches  string `yaml:"punches"` // list of clock times
+
+	// yearly totals, read from the newest month
+	YearExpected string `yaml:"year_expected"`
+	YearWorked   string `yaml:"year_worked"`
+	YearOvertime string `yaml:"year_overtime"`
+}
+
+type TimenetSelectors struct {
+	Password      string `yaml:"password"`       // login input
+	ChecksLink    string `yaml:"checks_link"`    // navigation link to the checks page
+	MonthHeader   string `yaml:"month_header"`   // month navigation, holds the h2 with the month name
+	PreviousMonth string `yaml:"previous_month"` // button going back one month
+	MonthCard     string `yaml:"month_card"`     // content of one month
+	Punches       string `yaml:"punches"`        // each clock-in and clock-out time in a day row
+	Totals        string `yaml:"totals"`         // rows of expected, worked and overtime totals
+	DayRows       string `yaml:"day_rows"`       // one row per day in a month card
+
+	// cells of a day row
+	DayDate     string `yaml:"day_date"`
+	DayLabel    string `yaml:"day_label"` // day type label, e.g. "Festivo"
+	DayExpected string `yaml:"day_expected"`
+	DayWorked   string `yaml:"day_worked"`
+	DayOvertime string `yaml:"day_overtime"`
+}
+
+type KimaiConfig struct {
+	URL       string         `yaml:"url"`
+	APIURL    string         `yaml:"api_url"` // Kimai 2 REST API, used instead of url when set
+	Locale    string         `yaml:"locale"`  // language of the Kimai 2 pages linked, e.g. /en/timesheet/1/edit
+	Timeout   time.Duration  `yaml:"timeout"`
+	RowLimit  int            `yaml:"row_limit"` // entries per page set in the preferences
+	Selectors KimaiSelectors `yaml:"selectors"`
+}
+
+type KimaiSelectors struct {
+	Username       string `yaml:"username"`
+	Password       string `yaml:"password"`
+	LoginButton    string `yaml:"login_button"`
+	Floater        string `yaml:"floater"`          // preferences panel
+	PrefsTab       string `yaml:"prefs_tab"`        // tab of the preferences panel with the row limit
+	RowLimit       string `yaml:"row_limit"`        // row limit input
+	Dates          string `yaml:"dates"`            // date filter
+	TimeSheetTable string `yaml:"time_sheet_table"` // holds the table of entries
+	FromField      string `yaml:"from_field"`       // shown start of the date filter
+	ToField        string `yaml:"to_field"`         // shown end of the date filter
+	FromInput      string `yaml:"from_input"`       // hidden input with the datepicker of the start
+	ToInput        string `yaml:"to_input"`         // hidden input with the datepicker of the end
+	LoggedinUser   string `yaml:"loggedin_user"`
+	Total          string `yaml:"total"` // logged time of the whole range
+
+	// cells of an entry row
+	Columns KimaiColumns `yaml:"columns"`
+}
+
+type KimaiColumns struct {
+	Date        string `yaml:"date"`
+	From        string `yaml:"from"`
+	To          string `yaml:"to"`
+	Time        string `yaml:"time"`
+	Customer    string `yaml:"customer"`
+	Project     string `yaml:"project"`
+	Activity    string `yaml:"activity"`
+	Username    string `yaml:"username"`
+	Description string `yaml:"description"`
+	Billable    string `yaml:"billable"`
+	Rate        string `yaml:"rate"`   // hourly rate
+	Amount      string `yaml:"amount"` // billed for the entry
+}
+
+// configuration in use, loaded at start up
+var config = defaultConfig()
+
+// the values timo always had, for the ITK Timenet tenant and Kimai host
+func defaultConfig() Config {
+	return Config{
+		Timenet: TimenetConfig{
+			URL:      "https://timenet-wcp.gpisoftware.com",
+			TenantID: "28b27216-c0c8-469c-816b-c65d0a11c7dd",
+			Timeout:  35 * time.Second,
+			Selectors: TimenetSelectors{
+				Password:      "#gpi-input-0",
+				ChecksLink:    `a.nav-link[href="/checks"]`,
+				MonthHeader:   "div.container-mes-checks",
+				PreviousMonth: "div.container-mes-checks button:first-child",
+				MonthCard:     "div.card",
+				Punches:       "td.checks-day-check span",
+				Totals:        "table.table-resum-hores tbody tr",
+				DayRows:       "table.table-checks tbody tr",
+				DayDate:       ".day-value",
+				DayLabel:      ".day-type-name",
+				DayExpected:   ".prevision-day-check",
+				DayWorked:     ".total-day-check span",
+				DayOvertime:   ".diff-day-check span",
+			},
+			// network mode has no defaults, the backend of each tenant differs
+			Mode: "dom",
+		},
+		Kimai: KimaiConfig{
+			URL:      "https://kimai.itk-spain.com/index.php",
+			Locale:   "en",
+			Timeout:  35 * time.Second,
+			RowLimit: 920,
+			Selectors: KimaiSelectors{
+				Username:       "#kimaiusername",
+				Password:       "#kimaipassword",
+				LoginButton:    "#loginButton",
+				Floater:        "#floater",
+				PrefsTab:       "#floater .menu.tabSelection li:nth-child(3)",
+				RowLimit:       "#rowlimit",
+				Dates:          "#dates",
+				TimeSheetTable: "#timeSheetTable",
+				FromField:      "#ts_in",
+				ToField:        "#ts_out",
+				FromInput:      "#pick_in",
+				ToInput:        "#pick_out",
+				LoggedinUser:   "#top #menu b",
+				Total:          "#display_total",
+				Columns: KimaiColumns{
+					Date:        "td.date",
+					From:        "td.from",
+					To:          "td.to",
+					Time:        "td.time",
+					Customer:    "td.customer",
+					Project:     "td.project",
+					Activity:    "td.activity",
+					Username:    "td.username",
+					Description: "td.description",
+					Billable:    "td.billable",
+					Rate:        "td.rate",
+					Amount:      "td.wage",
+				},
+			},
+		},
+	}
+}
+
+// returns the path of the config file, ~/.config/timo/config.yaml in Linux
+func configPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "timo", "config.yaml"), nil
+}
+
+// loads the config file over the defaults, which are used alone when it does not exist
+func loadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		slog.Info("No config file found, using default config", "path", path)
+		return cfg, nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		return Config{}, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	slog.Info("Config file loaded", "path", path)
+	return cfg, nil
+}
+
+func (c Config) validate() error {
+	if c.Timenet.URL == "" || c.Timenet.TenantID == "" {
+		return fmt.Errorf("timenet url and tenant_id cannot be blank")
+	}
+	if c.Kimai.URL == "" && c.Kimai.APIURL == "" {
+		return fmt.Errorf("kimai url or api_url must be set")
+	}
+	if _, err := url.Parse(c.Kimai.URL); err != nil {
+		return fmt.Errorf("kimai url is not valid: %v", err)
+	}
+	if c.Timenet.Timeout <= 0 || c.Kimai.Timeout <= 0 {
+		return fmt.Errorf("timeouts must be positive, e.g. 35s")
+	}
+	if c.Kimai.RowLimit <= 0 {
+		return fmt.Errorf("kimai row_limit must be positive")
+	}
+	switch c.Timenet.Mode {
+	case "dom":
+	case "network":
+		network := c.Timenet.Network
+		if network.URLPattern == "" {
+			return fmt.Errorf("timenet network mode needs the url_pattern of your backend, see the README")
+		}
+		if _, err := regexp.Compile(network.URLPattern); err != nil {
+			return fmt.Errorf("timenet network url_pattern must be a valid regex")
+		}
+		if network.Fields.Days == "" || network.Fields.Date == "" ||
+			network.Fields.Expected == "" || network.Fields.Worked == "" {
+			return fmt.Errorf("timenet network fields days, date, expected and worked must be set")
+		}
+	default:
+		return fmt.Errorf("timenet mode must be dom or network, not '%s'", c.Timenet.Mode)
+	}
+	if err := validateDayTypeLabels(c.Timenet.DayTypes); err != nil {
+		return fmt.Errorf("timenet %v", err)
+	}
+	return nil
+}
+
+// returns the Timenet login page of the tenant
+func (c TimenetConfig) loginURL() string {
+	return strings.TrimRight(c.URL, "/") + "/login/" + c.TenantID
+}
+
+// returns the Timenet checks page, only shown when logged in
+func (c TimenetConfig) checksURL() string {
+	return strings.TrimRight(c.URL, "/") + "/checks"
+}
+
+// returns the Kimai 1 main page next to the login page, only shown when logged in
+func (c KimaiConfig) homeURL() string {
+	base, err := url.Parse(c.URL)
+	if err != nil {
+		return c.URL
+	}
+	return base.ResolveReference(&url.URL{Path: "core/kimai.php"}).String()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"unicode"
+)
+
+// environment variables read by the headless commands
+const (
+	envTimenetPassword = "TIMO_TIMENET_PASSWORD"
+	envKimaiID         = "TIMO_KIMAI_ID"
+	envKimaiPassword   = "TIMO_KIMAI_PASSWORD"
+)
+
+// command printing the credentials as JSON, e.g. "pass show timo"
+// set with --credential-helper or credential_helper in config.yaml
+var credentialHelper string = ""
+
+// returns the credentials for unattended runs. Each field is taken from the
+// first place that has it: --kimai-id, --password-stdin, environment
+// variables and then the credential helper.
+func unattendedCredentials(opts cliOptions, stdin io.Reader) (Credentials, error) {
+	creds := Credentials{KimaiID: opts.kimaiID}
+
+	if opts.passwordStdin {
+		fromStdin, err := readStdinCredentials(stdin)
+		if err != nil {
+			return creds, err
+		}
+		creds = creds.fill(fromStdin)
+	}
+
+	creds = creds.fill(Credentials{
+		TimenetPassword: os.Getenv(envTimenetPassword),
+		KimaiID:         os.Getenv(envKimaiID),
+		KimaiPassword:   os.Getenv(envKimaiPassword),
+	})
+
+	if credentialHelper != "" && !creds.complete() {
+		fromHelper, err := runCredentialHelper(credentialHelper)
+		if err != nil {
+			return creds, err
+		}
+		creds = creds.fill(fromHelper)
+	}
+	return creds, nil
+}
+
+// reads the Timenet password from the first line and the Kimai password from
+// the second one, like docker login --password-stdin
+func readStdinCredentials(stdin io.Reader) (Credentials, error) {
+	var lines []string
+	scanner := bufio.NewScanner(stdin)
+	for len(lines) < 2 && scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return Credentials{}, fmt.Errorf("failed to read passwords from stdin: %v", err)
+	}
+	if len(lines) < 2 {
+		return Credentials{}, fmt.Errorf("--password-stdin expects the Timenet password and the Kimai password on two lines")
+	}
+	return Credentials{TimenetPassword: lines[0], KimaiPassword: lines[1]}, nil
+}
+
+// runs the credential helper with the shell and reads its JSON output:
+// {"timenet_password": "...", "kimai_id": "...", "kimai_password": "..."}
+func runCredentialHelper(command string) (Credentials, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		// the output might hold secrets, it is never shown
+		return Credentials{}, fmt.Errorf("credential helper failed: %v", err)
+	}
+	var creds Credentials
+	if err := json.Unmarshal(output, &creds); err != nil {
+		return Credentials{}, fmt.Errorf("credential helper must print JSON with timenet_password, kimai_id and kimai_password")
+	}
+	slog.Info("Credentials read from credential helper")
+	return creds, nil
+}
+
+// returns the credentials with their blank fields taken from other
+func (c Credentials) fill(other Credentials) Credentials {
+	if c.TimenetPassword == "" {
+		c.TimenetPassword = other.TimenetPassword
+	}
+	if c.KimaiID == "" {
+		c.KimaiID = other.KimaiID
+	}
+	if c.KimaiPassword == "" {
+		c.KimaiPassword = other.KimaiPassword
+	}
+	return c
+}
+
+// returns true when nothing needs to be asked, the Kimai ID can be blank with
+// the Kimai API where the password is a bearer token
+func (c Credentials) complete() bool {
+	return c.TimenetPassword != "" && c.KimaiPassword != "" && (c.KimaiID != "" || kimaiAPIURL != "")
+}
+
+// checks the credentials before any fetch starts
+func (c Credentials) validate() error {
+	fields := []struct{ name, value string }{
+		{"Timenet password", c.TimenetPassword},
+		{"Kimai ID", c.KimaiID},
+		{"Kimai password", c.KimaiPassword},
+	}
+	for _, field := range fields {
+		// a new line would submit the login forms before the end of the password
+		if strings.IndexFunc(field.value, unicode.IsControl) >= 0 {
+			return fmt.Errorf("%s contains control characters", field.name)
+		}
+	}
+	return nil
+}
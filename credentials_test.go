@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReadStdinCredentials reads two passwords and returns without waiting
+// for stdin to be closed
+func TestReadStdinCredentials(t *testing.T) {
+	stdin, w := io.Pipe()
+	defer w.Close()
+	go w.Write([]byte("timenet-secret\r\nkimai-secret\n"))
+
+	type result struct {
+		creds Credentials
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		creds, err := readStdinCredentials(stdin)
+		done <- result{creds, err}
+	}()
+
+	select {
+	case got := <-done:
+		if got.err != nil {
+			t.Fatalf("read: %v", got.err)
+		}
+		if got.creds.TimenetPassword != "timenet-secret" || got.creds.KimaiPassword != "kimai-secret" {
+			t.Errorf("got passwords '%s' and '%s', expected 'timenet-secret' and 'kimai-secret'",
+				got.creds.TimenetPassword, got.creds.KimaiPassword)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("still reading after the second line")
+	}
+
+	if _, err := readStdinCredentials(strings.NewReader("timenet-secret\n")); err == nil {
+		t.Error("expected an error with a single line")
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// DayType is the kind of a Timenet day, taken from the label next to its date
+type DayType string
+
+const (
+	DayWork               DayType = "work_day"
+	DayWeekend            DayType = "weekend" // Saturday, Sunday or any non working day
+	DayHoliday            DayType = "holiday"
+	DayVacation           DayType = "vacation"
+	DayMedicalLeave       DayType = "medical_leave"
+	DayPersonalLeave      DayType = "personal_leave"
+	DayTraining           DayType = "training"
+	DayRemoteWork         DayType = "remote_work"
+	DayCalendarAdjustment DayType = "calendar_adjustment"
+	DayUnknown            DayType = "unknown"
+)
+
+// DayTypeLabel gives the day type of the Timenet labels containing the
+// words of Match, compared ignoring case
+type DayTypeLabel struct {
+	Match string  `yaml:"match"`
+	Type  DayType `yaml:"type"`
+}
+
+// labels seen in Spanish, Catalan and English tenants, the first match wins.
+// Holidays go first, then vacation, medical leave and calendar adjustments as
+// timo always did, and longer labels before the ones they contain ("No
+// laborable" before "Laborable")
+var defaultDayTypeLabels = []DayTypeLabel{
+	{Match: "Festivo", Type: DayHoliday},
+	{Match: "Festiu", Type: DayHoliday},
+	{Match: "Holiday", Type: DayHoliday},
+
+	{Match: "Vacaciones", Type: DayVacation},
+	{Match: "Vacances", Type: DayVacation},
+	{Match: "Vacation", Type: DayVacation},
+
+	// before the weekend, "Weekend while on IT leave" is not a weekend
+	{Match: "baja con parte médico", Type: DayMedicalLeave},
+	{Match: "baja médica", Type: DayMedicalLeave},
+	{Match: "incapacidad temporal", Type: DayMedicalLeave},
+	{Match: "baixa mèdica", Type: DayMedicalLeave},
+	{Match: "incapacitat temporal", Type: DayMedicalLeave},
+	{Match: "IT leave", Type: DayMedicalLeave},
+	{Match: "sick", Type: DayMedicalLeave},
+	{Match: "Seek time", Type: DayMedicalLeave},
+
+	{Match: "Ajuste de calendario", Type: DayCalendarAdjustment},
+	{Match: "Ajust de calendari", Type: DayCalendarAdjustment},
+	{Match: "calendar adjustment", Type: DayCalendarAdjustment},
+
+	{Match: "Asuntos propios", Type: DayPersonalLeave},
+	{Match: "Permiso", Type: DayPersonalLeave},
+	{Match: "Assumptes propis", Type: DayPersonalLeave},
+	{Match: "Permís", Type: DayPersonalLeave},
+	{Match: "Personal leave", Type: DayPersonalLeave},
+	{Match: "Personal day", Type: DayPersonalLeave},
+
+	{Match: "Formación", Type: DayTraining},
+	{Match: "Formació", Type: DayTraining},
+	{Match: "Training", Type: DayTraining},
+
+	{Match: "Teletrabajo", Type: DayRemoteWork},
+	{Match: "Teletreball", Type: DayRemoteWork},
+	{Match: "Remote", Type: DayRemoteWork},
+	{Match: "Working from home", Type: DayRemoteWork},
+
+	{Match: "No laborable", Type: DayWeekend},
+	{Match: "No feiner", Type: DayWeekend},
+	{Match: "non working day", Type: DayWeekend},
+	{Match: "Fin de semana", Type: DayWeekend},
+	{Match: "Cap de setmana", Type: DayWeekend},
+	{Match: "Weekend", Type: DayWeekend},
+
+	{Match: "Laborable", Type: DayWork},
+	{Match: "Feiner", Type: DayWork},
+	{Match: "Working day", Type: DayWork},
+}
+
+func validDayType(dayType DayType) bool {
+	switch dayType {
+	case DayWork, DayWeekend, DayHoliday, DayVacation, DayMedicalLeave, DayPersonalLeave,
+		DayTraining, DayRemoteWork, DayCalendarAdjustment:
+		return true
+	}
+	return false
+}
+
+// validates the day types of the config, day_types in the timenet section
+func validateDayTypeLabels(labels []DayTypeLabel) error {
+	for i, label := range labels {
+		if len(labelWords(label.Match)) == 0 {
+			return fmt.Errorf("day type %d: match must have at least one word", i+1)
+		}
+		if !validDayType(label.Type) {
+			return fmt.Errorf("day type %d: unknown type '%s'", i+1, label.Type)
+		}
+	}
+	return nil
+}
+
+// returns the day type of a Timenet label and whether the label was found in
+// the table. The labels of the config are tried before the default ones. A
+// day with expected time and an unknown or blank label is a work day, as
+// Timenet only expects time on work days.
+func classifyDayType(label string, hasExpected bool) (DayType, bool) {
+	words := labelWords(label)
+	if len(words) > 0 {
+		for _, labels := range [][]DayTypeLabel{config.Timenet.DayTypes, defaultDayTypeLabels} {
+			for _, l := range labels {
+				if containsWords(words, labelWords(l.Match)) {
+					return l.Type, true
+				}
+			}
+		}
+	}
+	if hasExpected {
+		return DayWork, false
+	}
+	return DayUnknown, false
+}
+
+// returns the lower case words of a label
+func labelWords(label string) []string {
+	return strings.FieldsFunc(strings.ToLower(label), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// returns true if match appears in words as whole words, so "IT leave" is
+// found in "Weekend while on IT leave" but not in "Permit leave"
+func containsWords(words []string, match []string) bool {
+	if len(match) == 0 {
+		return false
+	}
+	for i := 0; i+len(match) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(match)], match) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestClassifyDayType tests the label table with labels of every language
+func TestClassifyDayType(t *testing.T) {
+	testCases := []struct {
+		label       string
+		hasExpected bool
+		expected    DayType
+		known       bool
+	}{
+		{"Laborable", true, DayWork, true},
+		{"non working day", false, DayWeekend, true},
+		{"No laborable", false, DayWeekend, true},
+		{"Festivo local", false, DayHoliday, true},
+		{"Festiu", false, DayHoliday, true},
+		{"Bank Holiday", false, DayHoliday, true},
+		{"Vacaciones", false, DayVacation, true},
+		{"Vacances", false, DayVacation, true},
+		{"baja con parte médico", false, DayMedicalLeave, true},
+		{"Weekend while on IT leave", false, DayMedicalLeave, true},
+		{"Asuntos propios", false, DayPersonalLeave, true},
+		{"Permís retribuït", false, DayPersonalLeave, true},
+		{"Formación", true, DayTraining, true},
+		{"Training", true, DayTraining, true},
+		{"Teletreball", true, DayRemoteWork, true},
+		{"Remote work", true, DayRemoteWork, true},
+		{"calendar adjustment", false, DayCalendarAdjustment, true},
+		{"", true, DayWork, false},
+		{"Jornada intensiva", true, DayWork, false},
+		{"Jornada intensiva", false, DayUnknown, false},
+		{"", false, DayUnknown, false},
+		{"Permit leave", false, DayUnknown, false},
+		{"Festivo durante baja médica", false, DayHoliday, true},
+		{"Vacation on a bank holiday", false, DayHoliday, true},
+		{"Vacaciones (Teletrabajo)", false, DayVacation, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s/%v", tc.label, tc.hasExpected), func(t *testing.T) {
+			result, known := classifyDayType(tc.label, tc.hasExpected)
+			if result != tc.expected || known != tc.known {
+				t.Errorf("classifyDayType('%s', %v) = %s, %v, expected %s, %v",
+					tc.label, tc.hasExpected, result, known, tc.expected, tc.known)
+			}
+		})
+	}
+}
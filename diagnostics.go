@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// severity of a parse issue: errors reject the fetched data, warnings are
+// shown but the data is stored
+const (
+	IssueWarning = "warning"
+	IssueError   = "error"
+)
+
+// ParseIssue is something wrong found in the data extracted by a parser,
+// usually the sign of a layout change in the site
+type ParseIssue struct {
+	Severity string `json:"severity"`
+	Source   string `json:"source"`
+	Where    string `json:"where,omitempty"` // month or day, e.g. "2025-03" or "2025-03-04"
+	Message  string `json:"message"`
+}
+
+func (i ParseIssue) String() string {
+	if i.Where == "" {
+		return i.Message
+	}
+	return i.Where + ": " + i.Message
+}
+
+// ParseDiagnostics is the list of issues found in one fetch
+type ParseDiagnostics []ParseIssue
+
+func (d *ParseDiagnostics) add(severity, source, where, format string, args ...any) {
+	*d = append(*d, ParseIssue{Severity: severity, Source: source, Where: where, Message: fmt.Sprintf(format, args...)})
+}
+
+func (d ParseDiagnostics) count(severity string) int {
+	n := 0
+	for _, issue := range d {
+		if issue.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+func (d ParseDiagnostics) hasErrors() bool {
+	return d.count(IssueError) > 0
+}
+
+// returns a one line summary for the status line, e.g.
+// "2 errors, 1 warning: 2025-03: blank monthly totals"
+func (d ParseDiagnostics) summary() string {
+	if len(d) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, severity := range []string{IssueError, IssueWarning} {
+		switch n := d.count(severity); n {
+		case 0:
+		case 1:
+			parts = append(parts, "1 "+severity)
+		default:
+			parts = append(parts, fmt.Sprintf("%d %ss", n, severity))
+		}
+	}
+	// the first error tells more than the first warning
+	first := d[0]
+	for _, issue := range d {
+		if issue.Severity == IssueError {
+			first = issue
+			break
+		}
+	}
+	return strings.Join(parts, ", ") + ": " + first.String()
+}
+
+// logs every issue
+func (d ParseDiagnostics) log() {
+	for _, issue := range d {
+		if issue.Severity == IssueError {
+			slog.Error(issue.Source+": Parse error", "where", issue.Where, "message", issue.Message)
+		} else {
+			slog.Warn(issue.Source+": Parse warning", "where", issue.Where, "message", issue.Message)
+		}
+	}
+}
+
+// checks the data returned by the parser of a source before it is stored
+func checkSourceData(name string, data any, r FetchRange) ParseDiagnostics {
+	switch d := data.(type) {
+	case TimenetData:
+		return checkTimenetData(name, d, r)
+	case KimaiData:
+		return checkKimaiData(name, d, r)
+	}
+	return nil
+}
+
+// the longest plausible day, anything over it means a wrong column was read
+const maxDayMinutes = 24 * 60
+
+// adds an error when minutes are out of the given bounds
+func checkMinutes(diags *ParseDiagnostics, source, where, field string, minutes, min, max int) {
+	if minutes < min || minutes > max {
+		diags.add(IssueError, source, where, "%s %s is not plausible", field, formatMinutes(minutes, true))
+	}
+}
+
+// returns the issues found by the parser itself, like blank or unreadable cells
+func parserIssues(source string, issues ParseDiagnostics) ParseDiagnostics {
+	var diags ParseDiagnostics
+	for _, issue := range issues {
+		issue.Source = source
+		diags = append(diags, issue)
+	}
+	return diags
+}
+
+// checks every month has its totals and all its days, with known day types
+// and times that fit in a day
+func checkTimenetData(source string, data TimenetData, r FetchRange) ParseDiagnostics {
+	diags := parserIssues(source, data.issues)
+	if len(data.MonthlyData) == 0 {
+		diags.add(IssueError, source, "", "no month found, the site layout may have changed")
+		return diags
+	}
+	if len(data.MonthlyData) != r.months() {
+		diags.add(IssueWarning, source, "", "%d months found, %d expected", len(data.MonthlyData), r.months())
+	}
+
+	for _, month := range data.MonthlyData {
+		key := month.Month
+		first, err := time.ParseInLocation("2006-01", key, time.Local)
+		if err != nil {
+			// already reported by the parser
+			continue
+		}
+		checkMinutes(&diags, source, key, "expected time", month.ExpectedMinutes, 0, 31*maxDayMinutes)
+		checkMinutes(&diags, source, key, "worked time", month.WorkedMinutes, 0, 31*maxDayMinutes)
+		checkMinutes(&diags, source, key, "overtime", month.OvertimeMinutes, -31*maxDayMinutes, 31*maxDayMinutes)
+
+		daysInMonth := first.AddDate(0, 1, -1).Day()
+		switch days := len(month.DailyData); {
+		case days == 0:
+			diags.add(IssueError, source, key, "no day found")
+		case days > daysInMonth:
+			diags.add(IssueError, source, key, "%d days found in a month of %d", days, daysInMonth)
+		case days < daysInMonth:
+			diags.add(IssueWarning, source, key, "%d days found in a month of %d", days, daysInMonth)
+		}
+
+		seen := make(map[string]bool)
+		for _, day := range month.DailyData {
+			date, err := time.ParseInLocation("2006-01-02", day.Date, time.Local)
+			if err != nil {
+				diags.add(IssueError, source, key, "invalid date '%s'", day.Date)
+				continue
+			}
+			if date.Format("2006-01") != key {
+				diags.add(IssueError, source, day.Date, "day out of its month %s", key)
+			}
+			if seen[day.Date] {
+				diags.add(IssueError, source, day.Date, "day found twice")
+			}
+			seen[day.Date] = true
+
+			// unknown labels are already reported by the parser
+			if day.DayType == DayUnknown && day.DayLabel == "" {
+				diags.add(IssueWarning, source, day.Date, "unknown day type")
+			}
+			checkMinutes(&diags, source, day.Date, "expected time", day.ExpectedMinutes, 0, maxDayMinutes)
+			checkMinutes(&diags, source, day.Date, "worked time", day.WorkedMinutes, 0, maxDayMinutes)
+			checkPunches(&diags, source, day)
+			checkMinutes(&diags, source, day.Date, "overtime", day.OvertimeMinutes, -maxDayMinutes, maxDayMinutes)
+		}
+	}
+	return diags
+}
+
+// checks the punches of a day come in clock-in and clock-out pairs, in order
+func checkPunches(diags *ParseDiagnostics, source string, day TimenetDailyData) {
+	if day.missingClockOut() {
+		diags.add(IssueWarning, source, day.Date, "odd number of punches, clock-out missing after %s",
+			day.Punches[len(day.Punches)-1].In)
+	}
+	for _, p := range day.Punches {
+		if p.Out != "" && p.Out < p.In {
+			diags.add(IssueWarning, source, day.Date, "clock-out %s before clock-in %s", p.Out, p.In)
+		}
+	}
+}
+
+// checks every entry has a date in the range, a plausible time and what it
+// was booked to, and that all the entries of the page were read
+func checkKimaiData(source string, data KimaiData, r FetchRange) ParseDiagnostics {
+	diags := parserIssues(source, data.issues)
+	if len(data.MonthlyData) == 0 {
+		diags.add(IssueWarning, source, "", "no entry found")
+	}
+
+	from, to := r.From.Format("2006-01"), r.To.Format("2006-01")
+	total := 0
+	for _, entry := range data.MonthlyData {
+		total += entry.WorkedMinutes
+		if _, err := time.ParseInLocation("2006-01-02", entry.Date, time.Local); err != nil {
+			diags.add(IssueError, source, "", "invalid date '%s'", entry.Date)
+			continue
+		}
+		if key := kimaiMonthKey(entry); key < from || key > to {
+			diags.add(IssueWarning, source, entry.Date, "entry out of the fetch range")
+		}
+		checkMinutes(&diags, source, entry.Date, "worked time", entry.WorkedMinutes, 0, maxDayMinutes)
+		if entry.Project == "" && entry.Activity == "" {
+			diags.add(IssueWarning, source, entry.Date, "entry without project nor activity")
+		}
+	}
+
+	// the page total covers all the entries of the filter, also the ones
+	// left out by the row limit
+	if data.Summary.LoggedMinutes != total {
+		diags.add(IssueWarning, source, "", "entries add up to %s but the total is %s, some rows may be missing",
+			formatMinutes(total, false), formatMinutes(data.Summary.LoggedMinutes, false))
+	}
+	return diags
+}
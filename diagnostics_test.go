@@ -0,0 +1,80 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseDiagnostics checks the issues found in good and broken data
+func TestParseDiagnostics(t *testing.T) {
+	march := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.Local)
+	r := FetchRange{From: march, To: march}
+
+	// blank and unreadable cells are found by the parser, so those cases
+	// start from the HTML of the fake Timenet
+	card := fakeTimenetCard(march)
+	parse := func(html string) TimenetData {
+		html = `<div class="card">` + html + `</div>`
+		data, _ := timenetParse(&html)
+		return data
+	}
+	good := parse(card)
+	blankTotals := parse(regexp.MustCompile(`(<td>Trabajado</td><td>)\d+h`).ReplaceAllString(card, "$1"))
+	wrongColumn := parse(strings.Replace(card, `<span>8h</span>`, `<span>Laborable</span>`, 1))
+
+	missingDays := parse(card)
+	missingDays.MonthlyData[0].DailyData = missingDays.MonthlyData[0].DailyData[:30]
+
+	unknownType := parse(card)
+	unknownType.MonthlyData[0].DailyData[0].DayType = DayUnknown
+	unknownType.MonthlyData[0].DailyData[0].DayLabel = ""
+
+	unknownLabel := parse(strings.Replace(card, `non working day`, `Jornada reducida`, 1))
+
+	// the first day of March 2025 is a Saturday, the third a Monday
+	withPunches := parse(strings.Replace(card, `<td class="checks-day-check"><span>08:00</span><span>12:00</span>`,
+		`<td class="checks-day-check"><span>08:00</span><span>12:00</span><span>12:30</span>`, 1))
+	wrongOrder := parse(strings.Replace(card, `<span>12:00</span>`, `<span>07:00</span>`, 1))
+
+	tooLong := parse(card)
+	tooLong.MonthlyData[0].DailyData[2].WorkedMinutes = 31 * 60
+
+	entry := KimaiMonthlyData{Date: "2025-03-03", In: "09:00", Out: "13:00", WorkedMinutes: 240, Project: "Timo", Activity: "Development"}
+	goodKimai := KimaiData{Summary: KimaiSummary{LoggedMinutes: 240}, MonthlyData: []KimaiMonthlyData{entry}}
+	missingRows := KimaiData{Summary: KimaiSummary{LoggedMinutes: 480}, MonthlyData: []KimaiMonthlyData{entry}}
+	badDate := KimaiData{Summary: KimaiSummary{LoggedMinutes: 240}, MonthlyData: []KimaiMonthlyData{entry}}
+	badDate.MonthlyData = []KimaiMonthlyData{{Date: "03/03/2025", WorkedMinutes: 240, Project: "Timo"}}
+
+	testCases := []struct {
+		name     string
+		diags    ParseDiagnostics
+		errors   int
+		warnings int
+	}{
+		{"good month", checkTimenetData("Timenet", good, r), 0, 0},
+		{"no month", checkTimenetData("Timenet", TimenetData{}, r), 1, 0},
+		{"blank totals", checkTimenetData("Timenet", blankTotals, r), 1, 0},
+		{"missing day", checkTimenetData("Timenet", missingDays, r), 0, 1},
+		{"unknown day type", checkTimenetData("Timenet", unknownType, r), 0, 1},
+		{"unknown day label", checkTimenetData("Timenet", unknownLabel, r), 0, 1},
+		{"wrong column", checkTimenetData("Timenet", wrongColumn, r), 1, 0},
+		{"implausible time", checkTimenetData("Timenet", tooLong, r), 1, 0},
+		{"missing clock-out", checkTimenetData("Timenet", withPunches, r), 0, 1},
+		{"clock-out before clock-in", checkTimenetData("Timenet", wrongOrder, r), 0, 1},
+		{"good entries", checkKimaiData("Kimai", goodKimai, r), 0, 0},
+		{"missing rows", checkKimaiData("Kimai", missingRows, r), 0, 1},
+		{"invalid date", checkKimaiData("Kimai", badDate, r), 1, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errors, warnings := tc.diags.count(IssueError), tc.diags.count(IssueWarning)
+			if errors != tc.errors || warnings != tc.warnings {
+				t.Errorf("got %d errors, %d warnings, expected %d, %d (%s)",
+					errors, warnings, tc.errors, tc.warnings, tc.diags.summary())
+			}
+		})
+	}
+}
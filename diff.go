@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// kinds of change between two fetches
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// Change is one day or entry that differs between two fetches
+type Change struct {
+	Kind   string `json:"kind"`
+	Date   string `json:"date"`
+	What   string `json:"what"`   // e.g. "worked 8h → 7h 30m" or "ACME / Timo / Development"
+	Delta  int    `json:"delta"`  // difference in minutes, new minus old
+	Source string `json:"source"` // name of the TimeSource
+}
+
+// SnapshotDiff lists what changed in a source between its last two fetches
+type SnapshotDiff struct {
+	Source   string    `json:"source"`
+	OldFetch time.Time `json:"old_fetch"` // zero when there are not two fetches to compare yet
+	NewFetch time.Time `json:"new_fetch"`
+	Months   []string  `json:"months"` // months in format YYYY-MM present in both fetches
+	Changes  []Change  `json:"changes"`
+}
+
+// compares the last two fetches of a source, only over the months fetched both times
+func diffLastFetches(name string) (*SnapshotDiff, error) {
+	records, err := history.lastFetches(name, 2)
+	if err != nil {
+		return nil, err
+	}
+	result := &SnapshotDiff{Source: name}
+	if len(records) < 2 {
+		return result, nil
+	}
+	newer, older := records[0], records[1]
+	result.NewFetch = newer.FetchedAt
+	result.OldFetch = older.FetchedAt
+
+	months := make(map[string]bool)
+	for _, key := range overlappingMonths(older, newer) {
+		months[key] = true
+		result.Months = append(result.Months, key)
+	}
+
+	kind := TimesheetSource
+	for _, r := range sourceRegistry {
+		if r.name == name {
+			kind = r.kind
+		}
+	}
+
+	switch kind {
+	case AttendanceSource:
+		// snapshots taken by older versions are migrated on the fly
+		oldData, err := decodeTimenetData(older.Snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s snapshot: %v", name, err)
+		}
+		newData, err := decodeTimenetData(newer.Snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s snapshot: %v", name, err)
+		}
+		result.Changes = diffTimenet(oldData, newData, months)
+	case TimesheetSource:
+		oldData, err := decodeKimaiData(older.Snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s snapshot: %v", name, err)
+		}
+		newData, err := decodeKimaiData(newer.Snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s snapshot: %v", name, err)
+		}
+		result.Changes = diffKimai(oldData, newData, months)
+	}
+	for i := range result.Changes {
+		result.Changes[i].Source = name
+	}
+	return result, nil
+}
+
+// returns the months in format YYYY-MM fetched by both records
+func overlappingMonths(a, b fetchRecord) []string {
+	from := max(a.From, b.From)
+	to := min(a.To, b.To)
+	fromMonth, err1 := parseFetchMonth(from)
+	toMonth, err2 := parseFetchMonth(to)
+	if err1 != nil || err2 != nil || fromMonth.After(toMonth) {
+		return nil
+	}
+	return rangeMonthKeys(FetchRange{From: fromMonth, To: toMonth})
+}
+
+// compares Timenet days, a day is modified when its worked, expected or
+// overtime values change
+func diffTimenet(oldData, newData TimenetData, months map[string]bool) []Change {
+	oldDays := timenetDaysByDate(oldData, months)
+	newDays := timenetDaysByDate(newData, months)
+
+	var changes []Change
+	for date, newDay := range newDays {
+		oldDay, found := oldDays[date]
+		if !found {
+			changes = append(changes, Change{Kind: ChangeAdded, Date: date,
+				What: "worked " + formatMinutes(newDay.WorkedMinutes, false), Delta: newDay.WorkedMinutes})
+			continue
+		}
+		var what []string
+		if oldDay.WorkedMinutes != newDay.WorkedMinutes {
+			what = append(what, fmt.Sprintf("worked %s → %s", formatMinutes(oldDay.WorkedMinutes, false), formatMinutes(newDay.WorkedMinutes, false)))
+		}
+		if oldDay.ExpectedMinutes != newDay.ExpectedMinutes {
+			what = append(what, fmt.Sprintf("expected %s → %s", formatMinutes(oldDay.ExpectedMinutes, false), formatMinutes(newDay.ExpectedMinutes, false)))
+		}
+		if oldDay.OvertimeMinutes != newDay.OvertimeMinutes {
+			what = append(what, fmt.Sprintf("overtime %s → %s", formatMinutes(oldDay.OvertimeMinutes, true), formatMinutes(newDay.OvertimeMinutes, true)))
+		}
+		if len(what) > 0 {
+			changes = append(changes, Change{Kind: ChangeModified, Date: date, What: strings.Join(what, ", "),
+				Delta: newDay.WorkedMinutes - oldDay.WorkedMinutes})
+		}
+	}
+	for date, oldDay := range oldDays {
+		if _, found := newDays[date]; !found {
+			changes = append(changes, Change{Kind: ChangeRemoved, Date: date,
+				What: "worked " + formatMinutes(oldDay.WorkedMinutes, false), Delta: -oldDay.WorkedMinutes})
+		}
+	}
+	sortChanges(changes)
+	return changes
+}
+
+func timenetDaysByDate(data TimenetData, months map[string]bool) map[string]TimenetDailyData {
+	days := make(map[string]TimenetDailyData)
+	for _, month := range data.MonthlyData {
+		if !months[month.Month] {
+			continue
+		}
+		for _, day := range month.DailyData {
+			days[day.Date] = day
+		}
+	}
+	return days
+}
+
+// compares Kimai entries, matched by ID or else by date, start time,
+// customer, project and activity. An entry is modified when its start, end,
+// duration or what it is booked to changes.
+func diffKimai(oldData, newData KimaiData, months map[string]bool) []Change {
+	oldEntries := kimaiEntriesByKey(oldData, months)
+	newEntries := kimaiEntriesByKey(newData, months)
+
+	var changes []Change
+	for key, newEntry := range newEntries {
+		oldEntry, found := oldEntries[key]
+		if !found {
+			changes = append(changes, Change{Kind: ChangeAdded, Date: newEntry.Date,
+				What: kimaiEntryLabel(newEntry) + " " + formatMinutes(newEntry.WorkedMinutes, false), Delta: newEntry.WorkedMinutes})
+			continue
+		}
+		if oldEntry.WorkedMinutes != newEntry.WorkedMinutes || oldEntry.Out != newEntry.Out ||
+			kimaiEntryLabel(oldEntry) != kimaiEntryLabel(newEntry) {
+			changes = append(changes, Change{Kind: ChangeModified, Date: newEntry.Date,
+				What:  fmt.Sprintf("%s %s → %s", kimaiEntryLabel(newEntry), formatMinutes(oldEntry.WorkedMinutes, false), formatMinutes(newEntry.WorkedMinutes, false)),
+				Delta: newEntry.WorkedMinutes - oldEntry.WorkedMinutes})
+		}
+	}
+	for key, oldEntry := range oldEntries {
+		if _, found := newEntries[key]; !found {
+			changes = append(changes, Change{Kind: ChangeRemoved, Date: oldEntry.Date,
+				What: kimaiEntryLabel(oldEntry) + " " + formatMinutes(oldEntry.WorkedMinutes, false), Delta: -oldEntry.WorkedMinutes})
+		}
+	}
+	sortChanges(changes)
+	return changes
+}
+
+func kimaiEntriesByKey(data KimaiData, months map[string]bool) map[string]KimaiMonthlyData {
+	entries := make(map[string]KimaiMonthlyData)
+	seen := make(map[string]int)
+	for _, entry := range data.MonthlyData {
+		if !months[kimaiMonthKey(entry)] {
+			continue
+		}
+		if entry.ID != 0 {
+			entries[fmt.Sprintf("id:%d", entry.ID)] = entry
+			continue
+		}
+		// the same entry may be booked twice, keep both apart
+		key := strings.Join([]string{entry.Date, entry.In, entry.Customer, entry.Project, entry.Activity}, "|")
+		seen[key]++
+		entries[fmt.Sprintf("%s|%d", key, seen[key])] = entry
+	}
+	return entries
+}
+
+func kimaiEntryLabel(entry KimaiMonthlyData) string {
+	return fmt.Sprintf("%s %s / %s / %s", entry.In, entry.Customer, entry.Project, entry.Activity)
+}
+
+func sortChanges(changes []Change) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Date != changes[j].Date {
+			return changes[i].Date < changes[j].Date
+		}
+		return changes[i].What < changes[j].What
+	})
+}
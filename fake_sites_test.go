@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FAKE TIMENET AND KIMAI SITES
+// Offline copies of the pages scrapeTimenet and scrapeKimai go through, with
+// the same selectors and the same behaviour: login, the Timenet checks page
+// with its month navigation and the backend JSON it loads, the Kimai
+// preferences floater and datepicker.
+// Used by TestScrapers to run the real chromedp flows with no network.
+
+var fakeSpanishMonths = []string{"Enero", "Febrero", "Marzo", "Abril", "Mayo", "Junio",
+	"Julio", "Agosto", "Septiembre", "Octubre", "Noviembre", "Diciembre"}
+
+// number of months, back from the current one, the fake sites have data for
+const fakeMonths = 6
+
+// how long the fake checks page takes to show the month before after a click
+var fakeTimenetClickDelay time.Duration
+
+// returns the first day of the month n months before the current one
+func fakeMonth(n int) time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month()-time.Month(n), 1, 0, 0, 0, 0, time.Local)
+}
+
+func isWeekend(day time.Time) bool {
+	return day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+}
+
+// serves a fake Timenet tenant accepting only the given password
+func newFakeTimenet(tenantID string, password string) *httptest.Server {
+	loggedIn := func(r *http.Request) bool {
+		cookie, err := r.Cookie("gpi_session")
+		return err == nil && cookie.Value == "ok"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/"+tenantID, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.FormValue("password") == password {
+			http.SetCookie(w, &http.Cookie{Name: "gpi_session", Value: "ok", Path: "/"})
+			http.Redirect(w, r, "/home", http.StatusSeeOther)
+			return
+		}
+		fmt.Fprint(w, `<html><body><form method="post">
+			<input id="gpi-input-0" name="password" type="password">
+			</form></body></html>`)
+	})
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		if !loggedIn(r) {
+			http.Redirect(w, r, "/login/"+tenantID, http.StatusSeeOther)
+			return
+		}
+		fmt.Fprint(w, `<html><body><nav><a class="nav-link" href="/checks">Fichajes</a></nav></body></html>`)
+	})
+	mux.HandleFunc("/checks", func(w http.ResponseWriter, r *http.Request) {
+		if !loggedIn(r) {
+			http.Redirect(w, r, "/login/"+tenantID, http.StatusSeeOther)
+			return
+		}
+		// the page opens on the current month, older months are rendered
+		// in the same card when going back
+		var cards []string
+		for n := 0; n < fakeMonths; n++ {
+			cards = append(cards, fakeTimenetCard(fakeMonth(n)))
+		}
+		cardsJSON, _ := json.Marshal(cards)
+		fmt.Fprintf(w, `<html><body>
+			<nav><a class="nav-link" href="/checks">Fichajes</a></nav>
+			<div class="card"></div>
+			<script>
+			var months = %s;
+			var offset = 0;
+			function render() {
+				document.querySelector("div.card").innerHTML = months[Math.min(offset, months.length - 1)];
+				// the current month is reloaded too, as a backend poll would
+				if (offset > 0) fetch("/api/v1/checks?offset=0");
+				fetch("/api/v1/checks?offset=" + Math.min(offset, months.length - 1));
+			}
+			document.addEventListener("click", function (e) {
+				if (e.target.closest("div.container-mes-checks button:first-child")) {
+					offset++;
+					setTimeout(render, %d);
+				}
+			});
+			render();
+			</script></body></html>`, cardsJSON, fakeTimenetClickDelay.Milliseconds())
+	})
+	// the backend the checks page loads each month from
+	mux.HandleFunc("/api/v1/checks", func(w http.ResponseWriter, r *http.Request) {
+		if !loggedIn(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fakeTimenetMonthJSON(fakeMonth(offset)))
+	})
+	return httptest.NewServer(mux)
+}
+
+// where the values are in the JSON of the fake backend, for mode: network
+var fakeTimenetNetwork = TimenetNetwork{
+	URLPattern: `/api/v1/checks`,
+	Fields: TimenetJSONFields{
+		Days:     "days",
+		Date:     "date",
+		DayLabel: "dayType",
+		Expected: "expected",
+		Worked:   "worked",
+		Overtime: "difference",
+		Punches:  "checks",
+
+		YearExpected: "year.expected",
+		YearWorked:   "year.worked",
+		YearOvertime: "year.difference",
+	},
+}
+
+// returns the backend JSON of one month, the same days as fakeTimenetCard
+func fakeTimenetMonthJSON(month time.Time) map[string]any {
+	var days []map[string]any
+	for day := month; day.Month() == month.Month(); day = day.AddDate(0, 0, 1) {
+		if isWeekend(day) {
+			days = append(days, map[string]any{"date": day.Format("2006-01-02"), "dayType": "non working day",
+				"expected": nil, "worked": 0, "difference": 0, "checks": []string{}})
+			continue
+		}
+		days = append(days, map[string]any{"date": day.Format("2006-01-02") + "T00:00:00", "dayType": "Laborable",
+			"expected": 480, "worked": "8h", "difference": 0, "checks": []string{"08:00", "12:00", "13:00", "17:00"}})
+	}
+	return map[string]any{
+		"year": map[string]any{"expected": 1760 * 60, "worked": 1700 * 60, "difference": -60 * 60},
+		"days": days,
+	}
+}
+
+// returns the content of the Timenet card of one month: 8h expected and
+// worked on every week day with a lunch break, nothing on weekends
+func fakeTimenetCard(month time.Time) string {
+	var rows strings.Builder
+	hours := 0
+	for day := month; day.Month() == month.Month(); day = day.AddDate(0, 0, 1) {
+		if isWeekend(day) {
+			fmt.Fprintf(&rows, `<tr><td><span class="day-value">%s</span><span class="day-type-name">non working day</span></td>
+				<td class="prevision-day-check"></td><td class="total-day-check"><span></span></td><td class="diff-day-check"><span></span></td>
+				<td class="checks-day-check"></td></tr>`,
+				day.Format("02/01/2006"))
+			continue
+		}
+		hours += 8
+		fmt.Fprintf(&rows, `<tr><td><span class="day-value">%s</span><span class="day-type-name">Laborable</span></td>
+			<td class="prevision-day-check">8h</td><td class="total-day-check"><span>8h</span></td><td class="diff-day-check"><span>0m</span></td>
+			<td class="checks-day-check"><span>08:00</span><span>12:00</span><span>13:00</span><span>17:00</span></td></tr>`,
+			day.Format("02/01/2006"))
+	}
+	return fmt.Sprintf(`<div class="container-mes-checks"><button>&lt;</button><h2>%s %d</h2><button>&gt;</button></div>
+		<table class="table-resum-hores"><tbody>
+		<tr><td>Previsto</td><td>%dh</td><td>1760h</td></tr>
+		<tr><td>Trabajado</td><td>%dh</td><td>1700h</td></tr>
+		<tr><td>Diferencia</td><td>0m</td><td>-60h</td></tr>
+		</tbody></table>
+		<table class="table-checks"><tbody>%s</tbody></table>`,
+		fakeSpanishMonths[month.Month()-1], month.Year(), hours, hours, rows.String())
+}
+
+// one timesheet row of the fake Kimai
+type fakeKimaiEntry struct {
+	ID       int    `json:"id"`
+	Date     string `json:"date"` // DD/MM/YYYY
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Time     string `json:"time"`
+	Customer string `json:"customer"`
+	Project  string `json:"project"`
+	Activity string `json:"activity"`
+	Username string `json:"username"`
+	Billable string `json:"billable"`
+	Wage     string `json:"wage"`
+}
+
+// returns two entries, 4h each, on every week day of the fake months, only
+// the development one is billable
+func fakeKimaiEntries(user string) []fakeKimaiEntry {
+	var entries []fakeKimaiEntry
+	for day := fakeMonth(fakeMonths - 1); !day.After(time.Now()); day = day.AddDate(0, 0, 1) {
+		if isWeekend(day) {
+			continue
+		}
+		date := day.Format("02/01/2006")
+		entries = append(entries,
+			fakeKimaiEntry{len(entries) + 1, date, "09:00:00", "13:00:00", "4:00:00", "ACME", "Timo", "Development", user, "✓", "200,00"},
+			fakeKimaiEntry{len(entries) + 2, date, "14:00:00", "18:00:00", "4:00:00", "ACME", "Timo", "Review", user, "✗", "0,00"})
+	}
+	return entries
+}
+
+// serves a fake Kimai 1 site accepting only the given user and password
+func newFakeKimai(user string, password string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.php", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.FormValue("name") == user && r.FormValue("password") == password {
+			http.SetCookie(w, &http.Cookie{Name: "kimai_key", Value: "ok", Path: "/"})
+			http.Redirect(w, r, "/core/kimai.php", http.StatusSeeOther)
+			return
+		}
+		fmt.Fprint(w, `<html><body><form method="post" action="/index.php?a=checklogin">
+			<input id="kimaiusername" name="name" type="text">
+			<input id="kimaipassword" name="password" type="password">
+			<button id="loginButton" type="submit">Login</button>
+			</form></body></html>`)
+	})
+	mux.HandleFunc("/core/kimai.php", func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("kimai_key"); err != nil || cookie.Value != "ok" {
+			http.Redirect(w, r, "/index.php", http.StatusSeeOther)
+			return
+		}
+		entriesJSON, _ := json.Marshal(fakeKimaiEntries(user))
+		// the view filter opens on the current month
+		first := fakeMonth(0)
+		last := first.AddDate(0, 1, -1)
+		fmt.Fprintf(w, fakeKimaiPage, user,
+			first.Format("02/01/2006"), last.Format("02/01/2006"),
+			first.Format("02/01/2006"), last.Format("02/01/2006"), entriesJSON)
+	})
+	return httptest.NewServer(mux)
+}
+
+// main page of the fake Kimai, with a tiny stand in for the jQuery UI
+// datepicker: $('#pick_in').datepicker('show') opens it on the field month
+const fakeKimaiPage = `<html><body>
+<div id="top"><div id="menu">Logged in as <b>%s</b></div></div>
+<div id="floater" style="display:none">
+	<ul class="menu tabSelection"><li>General</li><li>Display</li><li>List</li></ul>
+	<div id="prefs_list" style="display:none"><input id="rowlimit" value="100"></div>
+</div>
+<div id="dates">
+	<span id="ts_in">%s</span> - <span id="ts_out">%s</span>
+	<input type="hidden" id="pick_in" value="%s"><input type="hidden" id="pick_out" value="%s">
+</div>
+<div id="ui-datepicker-div" style="display:none"></div>
+<div id="timeSheetTable"><table><tbody></tbody></table></div>
+<div id="display_total"></div>
+<script>
+var entries = %s;
+var monthNames = ["January", "February", "March", "April", "May", "June", "July",
+	"August", "September", "October", "November", "December"];
+var picker = { input: null, year: 0, month: 0 };
+
+function parseDate(s) {
+	var p = s.split("/");
+	return new Date(+p[2], +p[1] - 1, +p[0]);
+}
+function pad(n) { return (n < 10 ? "0" : "") + n; }
+
+function floaterShow(url, name, x, y, width) {
+	document.getElementById("floater").style.display = "block";
+}
+document.querySelector("#floater .menu.tabSelection li:nth-child(3)").addEventListener("click", function () {
+	document.getElementById("prefs_list").style.display = "block";
+});
+document.getElementById("rowlimit").addEventListener("keydown", function (e) {
+	if (e.key === "Enter") {
+		document.getElementById("floater").style.display = "none";
+	}
+});
+
+function $(selector) {
+	var input = document.querySelector(selector);
+	return {
+		datepicker: function (command) {
+			if (command !== "show") return;
+			var d = parseDate(input.value);
+			picker = { input: input, year: d.getFullYear(), month: d.getMonth() };
+			drawPicker();
+			document.getElementById("ui-datepicker-div").style.display = "block";
+		}
+	};
+}
+
+function drawPicker() {
+	var first = new Date(picker.year, picker.month, 1);
+	var days = new Date(picker.year, picker.month + 1, 0).getDate();
+	var previousDays = new Date(picker.year, picker.month, 0).getDate();
+	var html = '<div class="ui-datepicker-header"><a class="ui-datepicker-prev">Prev</a><a class="ui-datepicker-next">Next</a>' +
+		'<div class="ui-datepicker-title">' + monthNames[picker.month] + ' ' + picker.year + '</div></div>' +
+		'<table class="ui-datepicker-calendar"><tbody><tr>';
+	var lead = (first.getDay() + 6) %% 7;
+	for (var i = lead - 1; i >= 0; i--) {
+		html += '<td class="ui-datepicker-other-month"><a href="#">' + (previousDays - i) + '</a></td>';
+	}
+	for (var day = 1; day <= days; day++) {
+		if ((lead + day - 1) %% 7 === 0) html += '</tr><tr>';
+		html += '<td><a href="#">' + day + '</a></td>';
+	}
+	html += '</tr></tbody></table>';
+	document.getElementById("ui-datepicker-div").innerHTML = html;
+}
+
+document.getElementById("ui-datepicker-div").addEventListener("click", function (e) {
+	e.preventDefault();
+	if (e.target.classList.contains("ui-datepicker-prev")) {
+		picker.month--;
+	} else if (e.target.classList.contains("ui-datepicker-next")) {
+		picker.month++;
+	} else if (e.target.tagName === "A" && !e.target.parentNode.classList.contains("ui-datepicker-other-month")) {
+		var value = pad(+e.target.textContent) + "/" + pad(picker.month + 1) + "/" + picker.year;
+		picker.input.setAttribute("value", value);
+		picker.input.value = value;
+		document.getElementById(picker.input.id === "pick_in" ? "ts_in" : "ts_out").textContent = value;
+		document.getElementById("ui-datepicker-div").style.display = "none";
+		renderTable();
+		return;
+	}
+	var d = new Date(picker.year, picker.month, 1);
+	picker.year = d.getFullYear();
+	picker.month = d.getMonth();
+	drawPicker();
+});
+
+function renderTable() {
+	var from = parseDate(document.getElementById("pick_in").value);
+	var to = parseDate(document.getElementById("pick_out").value);
+	var rows = "";
+	var total = 0;
+	entries.forEach(function (e) {
+		var d = parseDate(e.date);
+		if (d < from || d > to) return;
+		total += 4;
+		rows += '<tr id="timeSheetEntry' + e.id + '"><td class="date">' + e.date + '</td><td class="from">' + e.from + '</td><td class="to">' + e.to +
+			'</td><td class="time">' + e.time + '</td><td class="customer">' + e.customer +
+			'</td><td class="project"><a href="#">' + e.project + '</a></td><td class="activity"><a href="#">' + e.activity +
+			'</a></td><td class="username">' + e.username + '</td><td class="billable">' + e.billable +
+			'</td><td class="wage">' + e.wage + '</td></tr>';
+	});
+	document.querySelector("#timeSheetTable table tbody").innerHTML = rows;
+	document.getElementById("display_total").textContent = total + ":00:00";
+}
+renderTable();
+</script></body></html>`
+
+// returns the number of week days in the month
+func countWeekDays(month time.Time) int {
+	n := 0
+	for day := month; day.Month() == month.Month(); day = day.AddDate(0, 0, 1) {
+		if !isWeekend(day) {
+			n++
+		}
+	}
+	return n
+}
+
+func parseDDMMYYYY(date string) time.Time {
+	t, _ := time.ParseInLocation("02/01/2006", date, time.Local)
+	return t
+}
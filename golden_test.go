@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// folder with the parser fixtures: anonymized pages saved as name.html next
+// to the JSON the parser is expected to return as name.json. The file name
+// prefix tells the parser, timenet_ or kimai_.
+const goldenDir = "testdata/parsers"
+
+// writes the parser output as the expected JSON instead of comparing, set
+// with -update after checking the changes are wanted
+var updateGolden = flag.Bool("update", false, "rewrite the expected JSON of the parser fixtures")
+
+// parses one fixture with the parser of its prefix and returns the indented
+// JSON. The fetch time changes on every run so it is left blank.
+func parseGoldenFixture(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	html := string(content)
+
+	var data any
+	switch name := filepath.Base(path); {
+	case strings.HasPrefix(name, "timenet_"):
+		parsed, err := timenetParse(&html)
+		if err != nil {
+			return nil, err
+		}
+		parsed.FetchedAt = time.Time{}
+		data = parsed
+	case strings.HasPrefix(name, "kimai_"):
+		parsed, err := kimaiParse(&html)
+		if err != nil {
+			return nil, err
+		}
+		parsed.FetchedAt = time.Time{}
+		data = parsed
+	default:
+		return nil, fmt.Errorf("fixture name must start with timenet_ or kimai_")
+	}
+
+	output, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(output, '\n'), nil
+}
+
+// returns the first line that differs between the expected and the actual JSON
+func firstDiffLine(expected, actual []byte) string {
+	expectedLines := strings.Split(string(expected), "\n")
+	actualLines := strings.Split(string(actual), "\n")
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		var e, a string
+		if i < len(expectedLines) {
+			e = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			a = actualLines[i]
+		}
+		if e != a {
+			return fmt.Sprintf("line %d\n   expected: %s\n   actual:   %s", i+1, strings.TrimSpace(e), strings.TrimSpace(a))
+		}
+	}
+	return ""
+}
+
+// TestParserGolden runs timenetParse and kimaiParse on every fixture and
+// compares the output with the expected JSON, so a site redesign shows up as
+// a failure instead of silently empty fields
+func TestParserGolden(t *testing.T) {
+	fixtures, _ := filepath.Glob(filepath.Join(goldenDir, "*.html"))
+	if len(fixtures) == 0 {
+		t.Fatalf("no fixtures found in %s", goldenDir)
+	}
+
+	for _, fixture := range fixtures {
+		name := filepath.Base(fixture)
+		goldenFile := strings.TrimSuffix(fixture, ".html") + ".json"
+
+		t.Run(name, func(t *testing.T) {
+			actual, err := parseGoldenFixture(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *updateGolden {
+				if err := os.WriteFile(goldenFile, actual, 0644); err != nil {
+					t.Fatal(err)
+				}
+				t.Logf("updated %s", filepath.Base(goldenFile))
+				return
+			}
+
+			expected, err := os.ReadFile(goldenFile)
+			if err != nil {
+				t.Fatalf("%v, run go test -run TestParserGolden -update", err)
+			}
+			if diff := firstDiffLine(expected, actual); diff != "" {
+				t.Errorf("differs from %s at %s", filepath.Base(goldenFile), diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// base URL of a Kimai 2 instance, e.g. https://kimai.example.com
+// when set, Kimai data is read from its REST API instead of scraping
+var kimaiAPIURL string = ""
+
+// KIMAI 2 API SOURCE
+// uses /api/timesheets and needs no Chromium. The Kimai ID is sent as
+// X-AUTH-USER and the Kimai password as X-AUTH-TOKEN. With a blank Kimai ID
+// the password is used as bearer token (Kimai 2.14+ API tokens).
+type kimaiAPISource struct {
+	baseURL string
+	id      string
+	token   string
+	client  *http.Client
+}
+
+// raw payload returned by Fetch and read back by Parse
+type kimaiAPIPayload struct {
+	User       kimaiAPIUser        `json:"user"`
+	From       string              `json:"from"`
+	To         string              `json:"to"`
+	Timesheets []kimaiAPITimesheet `json:"timesheets"`
+}
+
+type kimaiAPIUser struct {
+	Username string `json:"username"`
+	Alias    string `json:"alias"`
+}
+
+// subset of the Kimai 2 expanded timesheet entity (?full=true)
+type kimaiAPITimesheet struct {
+	ID       int    `json:"id"`
+	Begin    string `json:"begin"`
+	End      string `json:"end"`
+	Duration int    `json:"duration"` // seconds
+
+	Description string  `json:"description"`
+	Billable    *bool   `json:"billable"` // missing before Kimai 1.15, all entries were billable
+	Rate        float64 `json:"rate"`     // billed for the entry
+	HourlyRate  float64 `json:"hourlyRate"`
+
+	Activity struct {
+		Name string `json:"name"`
+	} `json:"activity"`
+	Project struct {
+		Name     string `json:"name"`
+		Customer struct {
+			Name string `json:"name"`
+		} `json:"customer"`
+	} `json:"project"`
+}
+
+func newKimaiAPISource(baseURL string, id string, token string) *kimaiAPISource {
+	return &kimaiAPISource{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		id:      id,
+		token:   token,
+		client:  &http.Client{Timeout: config.Kimai.Timeout},
+	}
+}
+
+func (s *kimaiAPISource) Name() string { return "Kimai" }
+
+// sends an authenticated GET request to the Kimai API and decodes the JSON answer
+func (s *kimaiAPISource) get(ctx context.Context, path string, query url.Values, target any) (*http.Response, error) {
+	u := s.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.id != "" {
+		req.Header.Set("X-AUTH-USER", s.id)
+		req.Header.Set("X-AUTH-TOKEN", s.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Kimai API %s returned %s", path, resp.Status)
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		return nil, fmt.Errorf("failed to decode Kimai API %s: %v", path, err)
+	}
+	return resp, nil
+}
+
+// reads all timesheets of the given range, one page at a time
+func (s *kimaiAPISource) Fetch(ctx context.Context, r FetchRange) (string, error) {
+	from := time.Date(r.From.Year(), r.From.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := time.Date(r.To.Year(), r.To.Month()+1, 1, 0, 0, 0, 0, time.Local).Add(-time.Second)
+
+	payload := kimaiAPIPayload{
+		From: from.Format("2006-01-02"),
+		To:   to.Format("2006-01-02"),
+	}
+
+	slog.Info("Kimai API: Reading logged in user", "url", s.baseURL)
+	if _, err := s.get(ctx, "/api/users/me", nil, &payload.User); err != nil {
+		return "", fmt.Errorf("failed to login to Kimai API: %v", err)
+	}
+	reportProgress(ctx, fetchProgress{Stage: stageLoggedIn})
+
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("begin", from.Format("2006-01-02T15:04:05"))
+		query.Set("end", to.Format("2006-01-02T15:04:05"))
+		query.Set("full", "true")
+		query.Set("size", "500")
+		query.Set("page", strconv.Itoa(page))
+
+		var timesheets []kimaiAPITimesheet
+		resp, err := s.get(ctx, "/api/timesheets", query, &timesheets)
+		if err != nil {
+			return "", fmt.Errorf("failed to read Kimai timesheets: %v", err)
+		}
+		payload.Timesheets = append(payload.Timesheets, timesheets...)
+		slog.Info("Kimai API: Read timesheets page", "page", page, "entries", len(timesheets))
+
+		// X-Total-Pages is set by Kimai on paginated answers
+		totalPages, err := strconv.Atoi(resp.Header.Get("X-Total-Pages"))
+		if err != nil || page >= totalPages || len(timesheets) == 0 {
+			break
+		}
+	}
+
+	reportProgress(ctx, fetchProgress{Stage: stageMonth, Month: r.months()})
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// fills up the same KimaiData structure as the Kimai 1 HTML parser
+func (s *kimaiAPISource) Parse(raw *string) (any, error) {
+	return kimaiAPIParse(raw)
+}
+
+func kimaiAPIParse(raw *string) (KimaiData, error) {
+	if raw == nil {
+		return KimaiData{}, fmt.Errorf("Kimai API content is nil")
+	}
+
+	data := KimaiData{
+		SchemaVersion: dataSchemaVersion,
+		FetchedAt:     time.Now(),
+	}
+
+	var payload kimaiAPIPayload
+	if err := json.Unmarshal([]byte(*raw), &payload); err != nil {
+		return data, fmt.Errorf("failed to decode Kimai API content: %v", err)
+	}
+
+	data.Summary.LoggedinUser = payload.User.Alias
+	if data.Summary.LoggedinUser == "" {
+		data.Summary.LoggedinUser = payload.User.Username
+	}
+	data.Summary.ReportingDateFrom = payload.From
+	data.Summary.ReportingDateTo = payload.To
+
+	for _, ts := range payload.Timesheets {
+		begin, err := parseKimaiAPITime(ts.Begin)
+		if err != nil {
+			slog.Warn("Kimai API: Skipping entry with invalid begin", "id", ts.ID, "begin", ts.Begin)
+			continue
+		}
+
+		entry := KimaiMonthlyData{
+			ID:            ts.ID,
+			Date:          begin.Format("2006-01-02"),
+			In:            begin.Format("15:04"),
+			WorkedMinutes: ts.Duration / 60,
+			Customer:      ts.Project.Customer.Name,
+			Project:       ts.Project.Name,
+			Activity:      ts.Activity.Name,
+			Username:      payload.User.Username,
+			Description:   ts.Description,
+			Billable:      ts.Billable == nil || *ts.Billable,
+			HourlyRate:    ts.HourlyRate,
+			Amount:        ts.Rate,
+		}
+		// running entries have no end yet
+		if end, err := parseKimaiAPITime(ts.End); err == nil {
+			entry.Out = end.Format("15:04")
+		}
+
+		data.MonthlyData = append(data.MonthlyData, entry)
+	}
+
+	var dupes int
+	if data.MonthlyData, dupes = dedupeKimaiEntries(data.MonthlyData); dupes > 0 {
+		slog.Info("Kimai API: Skipped entries found twice", "count", dupes)
+	}
+	for _, entry := range data.MonthlyData {
+		data.Summary.LoggedMinutes += entry.WorkedMinutes
+	}
+
+	slog.Info("Kimai API: Parsed timesheet entries", "count", len(data.MonthlyData))
+	return data, nil
+}
+
+// Kimai answers with "+0100" style offsets, which time.RFC3339 does not accept
+func parseKimaiAPITime(value string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02T15:04:05-0700", value)
+	if err != nil {
+		return time.Parse(time.RFC3339, value)
+	}
+	return t, nil
+}
+
+// returns the page where an entry is edited in Kimai 2, in the language set
+// in the config, blank when reading Kimai 1 which has no page per entry
+func kimaiEntryURL(entry KimaiMonthlyData) string {
+	if kimaiAPIURL == "" || entry.ID == 0 {
+		return ""
+	}
+	base := strings.TrimRight(kimaiAPIURL, "/")
+	if config.Kimai.Locale != "" {
+		base += "/" + config.Kimai.Locale
+	}
+	return fmt.Sprintf("%s/timesheet/%d/edit", base, entry.ID)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestKimaiAPI runs the Kimai 2 API source against a local httptest stand-in
+func TestKimaiAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-AUTH-USER") != "jdoe" || r.Header.Get("X-AUTH-TOKEN") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/users/me":
+			fmt.Fprint(w, `{"id":1,"username":"jdoe","alias":"John Doe"}`)
+		case "/api/timesheets":
+			w.Header().Set("X-Total-Pages", "2")
+			if r.URL.Query().Get("page") == "1" {
+				fmt.Fprint(w, `[{"id":11,"begin":"2025-03-04T09:00:00+0100","end":"2025-03-04T13:30:00+0100","duration":16200,
+					"description":"Parser tests","billable":true,"rate":225,"hourlyRate":50,
+					"activity":{"name":"Development"},"project":{"name":"Timo","customer":{"name":"ACME"}}}]`)
+			} else {
+				// the first entry again, as when an entry is added while paging
+				fmt.Fprint(w, `[{"id":11,"begin":"2025-03-04T09:00:00+0100","end":"2025-03-04T13:30:00+0100","duration":16200,
+					"activity":{"name":"Development"},"project":{"name":"Timo","customer":{"name":"ACME"}}},
+					{"id":12,"begin":"2025-03-04T14:00:00+0100","end":"2025-03-04T14:45:00+0100","duration":2700,"billable":false,
+					"activity":{"name":"Lunch"},"project":{"name":"Break","customer":{"name":"ACME"}}}]`)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	r := FetchRange{
+		From: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.Local),
+		To:   time.Date(2025, time.March, 1, 0, 0, 0, 0, time.Local),
+	}
+	raw, err := newKimaiAPISource(server.URL, "jdoe", "secret").Fetch(context.Background(), r)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	data, err := kimaiAPIParse(&raw)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if len(data.MonthlyData) != 2 {
+		t.Fatalf("got %d entries, expected 2 from both pages without the one found twice", len(data.MonthlyData))
+	}
+	if data.Summary.LoggedinUser != "John Doe" {
+		t.Errorf("got user '%s', expected the alias 'John Doe'", data.Summary.LoggedinUser)
+	}
+	if got := data.Summary.ReportingDateFrom + "-" + data.Summary.ReportingDateTo; got != "2025-03-01-2025-03-31" {
+		t.Errorf("got range %s, expected the whole month", got)
+	}
+	if data.Summary.LoggedMinutes != 315 {
+		t.Errorf("got %d minutes logged, expected 315", data.Summary.LoggedMinutes)
+	}
+	entry := data.MonthlyData[0]
+	if entry.Date != "2025-03-04" || entry.In != "09:00" || entry.Out != "13:30" || entry.WorkedMinutes != 270 {
+		t.Errorf("got entry %s %s-%s %dm, expected 2025-03-04 09:00-13:30 270m", entry.Date, entry.In, entry.Out, entry.WorkedMinutes)
+	}
+	if entry.Customer != "ACME" {
+		t.Errorf("got customer %s, expected ACME", entry.Customer)
+	}
+	if entry.ID != 11 || entry.Description != "Parser tests" {
+		t.Errorf("got entry %d '%s', expected 11 'Parser tests'", entry.ID, entry.Description)
+	}
+	if entry.HourlyRate != 50 || entry.Amount != 225 {
+		t.Errorf("got rate %v and amount %v, expected 50 and 225", entry.HourlyRate, entry.Amount)
+	}
+	if !entry.Billable || data.MonthlyData[1].Billable {
+		t.Errorf("got billable %v and %v, expected true and false", entry.Billable, data.MonthlyData[1].Billable)
+	}
+	if data.MonthlyData[1].Project != "Break" {
+		t.Errorf("got project %s on the second page, expected Break", data.MonthlyData[1].Project)
+	}
+
+	if _, err := newKimaiAPISource(server.URL, "jdoe", "wrong").Fetch(context.Background(), r); err == nil {
+		t.Errorf("fetch with a wrong token did not fail")
+	}
+}
+
+// TestKimaiEntryURL tests the links to the Kimai 2 entries, with and
+// without the language segment
+func TestKimaiEntryURL(t *testing.T) {
+	savedConfig, savedURL := config, kimaiAPIURL
+	t.Cleanup(func() { config, kimaiAPIURL = savedConfig, savedURL })
+	config = defaultConfig()
+	entry := KimaiMonthlyData{ID: 11}
+
+	if got := kimaiEntryURL(entry); got != "" {
+		t.Errorf("got %s reading Kimai 1, expected no link", got)
+	}
+	kimaiAPIURL = "https://kimai.example.com/"
+	if got := kimaiEntryURL(entry); got != "https://kimai.example.com/en/timesheet/11/edit" {
+		t.Errorf("got %s, expected https://kimai.example.com/en/timesheet/11/edit", got)
+	}
+	config.Kimai.Locale = "de"
+	if got := kimaiEntryURL(entry); got != "https://kimai.example.com/de/timesheet/11/edit" {
+		t.Errorf("got %s, expected https://kimai.example.com/de/timesheet/11/edit", got)
+	}
+	config.Kimai.Locale = ""
+	if got := kimaiEntryURL(entry); got != "https://kimai.example.com/timesheet/11/edit" {
+		t.Errorf("got %s, expected https://kimai.example.com/timesheet/11/edit", got)
+	}
+	if got := kimaiEntryURL(KimaiMonthlyData{}); got != "" {
+		t.Errorf("got %s for an entry without ID, expected no link", got)
+	}
+}
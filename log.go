@@ -4,8 +4,50 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 )
 
+// secrets that must never reach the log, e.g. passwords
+var (
+	logSecrets   []string
+	logSecretsMu sync.Mutex
+)
+
+// hides the given secrets in every logged value from now on
+func hideInLog(secrets ...string) {
+	logSecretsMu.Lock()
+	defer logSecretsMu.Unlock()
+	for _, secret := range secrets {
+		if secret != "" {
+			logSecrets = append(logSecrets, secret)
+		}
+	}
+}
+
+// masks attributes named like a secret and any known secret found in a value
+func redactSecrets(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, name := range []string{"password", "passphrase", "token", "secret"} {
+		if strings.Contains(key, name) {
+			return slog.String(a.Key, "***")
+		}
+	}
+	if a.Value.Kind() != slog.KindString && a.Value.Kind() != slog.KindAny {
+		return a
+	}
+	value := a.Value.String()
+	logSecretsMu.Lock()
+	defer logSecretsMu.Unlock()
+	for _, secret := range logSecrets {
+		value = strings.ReplaceAll(value, secret, "***")
+	}
+	if value != a.Value.String() {
+		return slog.String(a.Key, value)
+	}
+	return a
+}
+
 // since the UI is using stdout/stderr for display, we cannot log there
 // so we create a log file in the OS temp folder
 func logInit(debugMode bool) {
@@ -18,11 +60,13 @@ func logInit(debugMode bool) {
 		if err != nil {
 			// Fallback to stderr if file creation fails
 			logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-				Level: slog.LevelWarn,
+				Level:       slog.LevelWarn,
+				ReplaceAttr: redactSecrets,
 			}))
 		} else {
 			logger = slog.New(slog.NewTextHandler(logFile, &slog.HandlerOptions{
-				Level: slog.LevelDebug,
+				Level:       slog.LevelDebug,
+				ReplaceAttr: redactSecrets,
 			}))
 			// Create a simple log file link in current directory
 			//os.Symlink(logFilePath, "timo_debug.log")
@@ -31,7 +75,8 @@ func logInit(debugMode bool) {
 	} else {
 		// For non-debug mode, create a minimal logger that discards output
 		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-			Level: slog.LevelError, // Only show errors
+			Level:       slog.LevelError, // Only show errors
+			ReplaceAttr: redactSecrets,
 		}))
 	}
 	slog.SetDefault(logger)
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"timo/reconcile"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/yosssi/gohtml"
 )
 
 func main() {
@@ -13,83 +18,193 @@ func main() {
 	// to get debug info use:  go run . --debug
 	// track logged data with: tail -f /tmp/timo_debug.log
 	debugMode := false
-	for _, arg := range os.Args[1:] {
-		if arg == "--debug" {
-			debugMode = true
-			break
+	showDiff := false
+	rulesFile := ""
+	configFile := ""
+	fetchRange := defaultFetchRange()
+	args := os.Args[1:]
+
+	// headless commands: timo fetch, timo report --month 2025-03, timo export --format csv, timo billing
+	command := ""
+	opts := cliOptions{}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
+	}
+	// action of the vault command: timo vault add
+	if command == "vault" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		opts.action = args[0]
+		args = args[1:]
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		// the value of a flag taking one is the next argument
+		value := ""
+		if valueFlags[arg] {
+			if i+1 == len(args) {
+				exitWithUsage("missing value for " + arg)
+			}
+			i++
+			value = args[i]
 		}
-		if arg == "--test" {
+
+		switch arg {
+		case "--debug":
+			debugMode = true
+		// read Kimai from a Kimai 2 REST API: --kimai-api https://kimai.example.com
+		case "--kimai-api":
+			kimaiAPIURL = value
+		// set the months to fetch: --from 2024-12 --to 2025-03
+		case "--from", "--to":
+			month, err := parseFetchMonth(value)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if arg == "--from" {
+				fetchRange.From = month
+			} else {
+				fetchRange.To = month
+			}
+		// months before the current one fetched again even if already stored
+		case "--refresh-months":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				fmt.Println("invalid --refresh-months value:", value)
+				os.Exit(1)
+			}
+			refreshMonths = n
+		// read URLs, selectors and timeouts from another file: --config my_config.yaml
+		case "--config":
+			configFile = value
+		// classify Kimai entries with another rules file: --rules my_rules.yaml
+		case "--rules":
+			rulesFile = value
+		// print what changed between the last two fetches and exit
+		case "--diff":
+			showDiff = true
+		// options of the headless commands
+		case "--month":
+			if _, err := parseFetchMonth(value); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			opts.month = value
+		case "--format":
+			opts.format = value
+		case "--punches":
+			opts.punches = true
+		case "--source":
+			opts.source = value
+		case "--kimai-id":
+			opts.kimaiID = value
+		// read the Timenet and Kimai passwords from two lines of stdin
+		case "--password-stdin":
+			opts.passwordStdin = true
+		// command printing the credentials as JSON: --credential-helper "pass show timo"
+		case "--credential-helper":
+			credentialHelper = value
+		// credentials profile stored in the vault: --profile work
+		case "--profile":
+			vaultProfile = value
+		case "--test":
 			testTimenetParsing()
 			//testKimaiParsing()
 			//test_tools_all()
 			return
+		default:
+			exitWithUsage("unexpected argument '" + arg + "'")
 		}
 	}
 	logInit(debugMode)
 
-	setupScraper()
-
-	model := newModel()
-	p := tea.NewProgram(model, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
-
+	if err := fetchRange.validate(); err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
 
-}
-
-func fetchTimenet(password string) error {
-
-	// SCRAPING
-	_html, err := scrapeTimenet(password)
+	if configFile == "" {
+		configFile, _ = configPath()
+	}
+	cfg, err := loadConfig(configFile)
 	if err != nil {
-		slog.Error("Failed to scrape Timenet", "error", err)
-		return err
+		fmt.Println(err)
+		os.Exit(1)
 	}
-
-	// clean up HTML
-	cleanHTML(&_html)
-
-	// DEBUG
-	if false {
-		_html = gohtml.Format(_html)
-		os.WriteFile("dump.html", []byte(_html), 0644)
+	config = cfg
+	// command line options win over the config file
+	if kimaiAPIURL == "" {
+		kimaiAPIURL = config.Kimai.APIURL
+	}
+	if credentialHelper == "" {
+		credentialHelper = config.CredentialHelper
 	}
 
-	// PARSE HTML AND SAVE IN LOCAL JSON
-	slog.Info("Starting Timenet data parsing")
-	err = timenetParse(&_html)
+	if rulesFile == "" {
+		rulesFile, _ = rulesPath()
+	}
+	rules, err := reconcile.LoadRules(rulesFile)
 	if err != nil {
-		slog.Error("Failed to parse Timenet data", "error", err)
-		return err
+		fmt.Println(err)
+		os.Exit(1)
 	}
+	reconcileRules = rules
 
-	return nil
-}
-
-func fetchKimai(id string, password string) error {
-
-	// SCRAPING
-	slog.Info("Starting Kimai scraping")
-	_html, err := scrapeKimai(id, password)
+	path, err := historyPath()
+	if err == nil {
+		history, err = openHistory(path)
+	}
 	if err != nil {
-		slog.Error("Failed to scrape Kimai", "error", err)
-		return err
+		fmt.Println("Failed to open local history:", err)
+		os.Exit(1)
 	}
+	defer history.Close()
+	history.importLegacyJSON()
 
-	// DEBUG
-	if false {
-		_html = gohtml.Format(_html)
-		os.WriteFile("dump.html", []byte(_html), 0644)
+	if showDiff {
+		command = "diff"
+	}
+	if command != "" {
+		opts.fetchRange = fetchRange
+		code := runCommand(command, opts)
+		history.Close()
+		os.Exit(code)
 	}
 
-	// PARSE HTML AND SAVE IN LOCAL JSON
-	slog.Info("Starting Kimai data parsing")
-	err = kimaiParse(&_html)
+	setupScraper()
+
+	model := newModel(fetchRange)
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	_, err = p.Run()
+	// fetches cancelled on quit are still closing Chromium
+	if !waitForFetches(10 * time.Second) {
+		slog.Warn("Fetches still running on exit, Chromium may be left open")
+	}
 	if err != nil {
-		slog.Error("Failed to parse Kimai data", "error", err)
-		return err
+		history.Close()
+		os.Exit(1)
 	}
 
-	return nil
+}
+
+// flags followed by a value
+var valueFlags = map[string]bool{
+	"--kimai-api": true, "--from": true, "--to": true, "--refresh-months": true,
+	"--config": true, "--rules": true, "--month": true, "--format": true,
+	"--source": true, "--kimai-id": true, "--credential-helper": true, "--profile": true,
+}
+
+const usage = `usage: timo [command] [flags]
+
+commands: fetch, report, export, diff, billing, vault add|list|remove
+flags:    --from YYYY-MM --to YYYY-MM --month YYYY-MM --refresh-months N
+          --format text|csv|json --punches --source timenet|kimai
+          --kimai-id ID --kimai-api URL --password-stdin --credential-helper CMD
+          --profile NAME --config FILE --rules FILE --diff --debug`
+
+// prints what is wrong with the command line and the usage, then exits
+func exitWithUsage(problem string) {
+	fmt.Fprintln(os.Stderr, problem)
+	fmt.Fprintln(os.Stderr, usage)
+	os.Exit(2)
 }
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DATA MODEL VERSION 1
+// written by timo before the schema version existed: times are display
+// strings like "9h 14m" or "-30m" and dates look like 2025/03/04
+
+type timenetDataV1 struct {
+	FetchDate                string           `json:"fetch_date"`
+	FetchTime                string           `json:"fetch_time"`
+	Year                     string           `json:"year"`
+	ExpectedWorkedTimeInYear string           `json:"expected_worked_time_in_year"`
+	WorkedTimeInYear         string           `json:"worked_time_in_year"`
+	OvertimeInYear           string           `json:"overtime_in_year"`
+	MonthlyData              []timenetMonthV1 `json:"monthly_data"`
+}
+
+type timenetMonthV1 struct {
+	Month                     string         `json:"month"` // English month name, e.g. "March"
+	Year                      string         `json:"year"`  // missing in the oldest JSON files
+	ExpectedWorkedTimeInMonth string         `json:"expected_worked_time_in_month"`
+	WorkedTimeInMonth         string         `json:"worked_time_in_month"`
+	OvertimeInMonth           string         `json:"overtime_in_month"`
+	DailyData                 []timenetDayV1 `json:"daily_data"`
+}
+
+type timenetDayV1 struct {
+	Date                    string `json:"date"`
+	ExpectedWorkedTimeInDay string `json:"expected_worked_time_in_day"`
+	WorkedTimeInDay         string `json:"worked_time_in_day"`
+	OvertimeInDay           string `json:"overtime_in_day"`
+	timenetDayFlags
+}
+
+// day type of version 1, one flag per type
+type timenetDayFlags struct {
+	IsWorkDay            bool `json:"is_work_day"`
+	IsHoliday            bool `json:"is_holiday"`
+	IsVacation           bool `json:"is_vacation"`
+	IsMedicalLeave       bool `json:"is_medical_leave"`
+	IsCalendarAdjustment bool `json:"is_calendar_adjustment"`
+	IsWeekend            bool `json:"is_weekend"`
+}
+
+// returns the day type of the flags, more than one flag could be set
+func (f timenetDayFlags) dayType() DayType {
+	switch {
+	case f.IsHoliday:
+		return DayHoliday
+	case f.IsVacation:
+		return DayVacation
+	case f.IsMedicalLeave:
+		return DayMedicalLeave
+	case f.IsCalendarAdjustment:
+		return DayCalendarAdjustment
+	case f.IsWeekend:
+		return DayWeekend
+	case f.IsWorkDay:
+		return DayWork
+	}
+	return DayUnknown
+}
+
+type kimaiDataV1 struct {
+	FetchDate     string         `json:"fetch_date"`
+	FetchTime     string         `json:"fetch_time"`
+	Summary       kimaiSummaryV1 `json:"summary"`
+	MonthlyData   []kimaiEntryV1 `json:"monthly_data"`
+	FetchedMonths []string       `json:"fetched_months,omitempty"`
+}
+
+type kimaiSummaryV1 struct {
+	ReportingDateFrom string `json:"reporting_date_from"` // DD/MM/YYYY
+	ReportingDateTo   string `json:"reporting_date_to"`
+	LoggedinUser      string `json:"loggedin_user"`
+	LoggedTime        string `json:"logged_time"`
+}
+
+type kimaiEntryV1 struct {
+	Date       string `json:"date"`
+	In         string `json:"in"` // clock time as a duration, e.g. "13h 30m"
+	Out        string `json:"out"`
+	WorkedTime string `json:"worked_time"`
+	Customer   string `json:"customer"`
+	Project    string `json:"project"`
+	Activity   string `json:"activity"`
+	Username   string `json:"username"`
+}
+
+// returns the time of a version 1 fetch, "2025/03/04" and "10:00"
+func fetchedAtV1(date, clock string) time.Time {
+	t, err := time.ParseInLocation("2006/01/02 15:04", date+" "+clock, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// converts a version 1 date, 2025/03/04, to 2025-03-04
+func isoDateV1(date string) string {
+	return strings.ReplaceAll(date, "/", "-")
+}
+
+// converts a version 1 clock time, "13h 30m", to 13:30
+func clockV1(value string) string {
+	if value == "" {
+		return ""
+	}
+	minutes := minutesOf(value)
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func (d timenetDataV1) migrate() TimenetData {
+	data := TimenetData{
+		SchemaVersion:         dataSchemaVersion,
+		FetchedAt:             fetchedAtV1(d.FetchDate, d.FetchTime),
+		ExpectedMinutesInYear: minutesOf(d.ExpectedWorkedTimeInYear),
+		WorkedMinutesInYear:   minutesOf(d.WorkedTimeInYear),
+		OvertimeMinutesInYear: minutesOf(d.OvertimeInYear),
+	}
+	data.Year, _ = strconv.Atoi(d.Year)
+	for _, month := range d.MonthlyData {
+		// the oldest files only stored the year once
+		if month.Year == "" {
+			month.Year = d.Year
+		}
+		data.MonthlyData = append(data.MonthlyData, month.migrate())
+	}
+	return data
+}
+
+func (m timenetMonthV1) migrate() TimenetMonthlyData {
+	month := TimenetMonthlyData{
+		ExpectedMinutes: minutesOf(m.ExpectedWorkedTimeInMonth),
+		WorkedMinutes:   minutesOf(m.WorkedTimeInMonth),
+		OvertimeMinutes: minutesOf(m.OvertimeInMonth),
+	}
+	if t, err := time.Parse("January 2006", m.Month+" "+m.Year); err == nil {
+		month.Month = t.Format("2006-01")
+	}
+	for _, day := range m.DailyData {
+		month.DailyData = append(month.DailyData, day.migrate())
+	}
+	return month
+}
+
+func (d timenetDayV1) migrate() TimenetDailyData {
+	return TimenetDailyData{
+		Date:            isoDateV1(d.Date),
+		ExpectedMinutes: minutesOf(d.ExpectedWorkedTimeInDay),
+		WorkedMinutes:   minutesOf(d.WorkedTimeInDay),
+		OvertimeMinutes: minutesOf(d.OvertimeInDay),
+		DayType:         d.dayType(),
+	}
+}
+
+func (d kimaiDataV1) migrate() KimaiData {
+	data := KimaiData{
+		SchemaVersion: dataSchemaVersion,
+		FetchedAt:     fetchedAtV1(d.FetchDate, d.FetchTime),
+		Summary: KimaiSummary{
+			ReportingDateFrom: convertDateFormat(d.Summary.ReportingDateFrom),
+			ReportingDateTo:   convertDateFormat(d.Summary.ReportingDateTo),
+			LoggedinUser:      d.Summary.LoggedinUser,
+			LoggedMinutes:     minutesOf(d.Summary.LoggedTime),
+		},
+		FetchedMonths: d.FetchedMonths,
+	}
+	for _, entry := range d.MonthlyData {
+		data.MonthlyData = append(data.MonthlyData, entry.migrate())
+	}
+	return data
+}
+
+func (e kimaiEntryV1) migrate() KimaiMonthlyData {
+	return KimaiMonthlyData{
+		Date:          isoDateV1(e.Date),
+		In:            clockV1(e.In),
+		Out:           clockV1(e.Out),
+		WorkedMinutes: minutesOf(e.WorkedTime),
+		Customer:      e.Customer,
+		Project:       e.Project,
+		Activity:      e.Activity,
+		Username:      e.Username,
+		Billable:      true,
+	}
+}
+
+// returns the schema version of a JSON document, 1 when it has none
+func schemaVersionOf(content []byte) (int, error) {
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(content, &header); err != nil {
+		return 0, err
+	}
+	if header.SchemaVersion == 0 {
+		return 1, nil
+	}
+	if header.SchemaVersion > dataSchemaVersion {
+		return 0, fmt.Errorf("data written by a newer timo (schema version %d), please update", header.SchemaVersion)
+	}
+	return header.SchemaVersion, nil
+}
+
+// reads Timenet data of any schema version
+func decodeTimenetData(content []byte) (TimenetData, error) {
+	version, err := schemaVersionOf(content)
+	if err != nil {
+		return TimenetData{}, err
+	}
+	if version == 1 {
+		var legacy timenetDataV1
+		if err := json.Unmarshal(content, &legacy); err != nil {
+			return TimenetData{}, err
+		}
+		return legacy.migrate(), nil
+	}
+	var data TimenetData
+	err = json.Unmarshal(content, &data)
+	return data, err
+}
+
+// reads Kimai data of any schema version
+func decodeKimaiData(content []byte) (KimaiData, error) {
+	version, err := schemaVersionOf(content)
+	if err != nil {
+		return KimaiData{}, err
+	}
+	if version == 1 {
+		var legacy kimaiDataV1
+		if err := json.Unmarshal(content, &legacy); err != nil {
+			return KimaiData{}, err
+		}
+		return legacy.migrate(), nil
+	}
+	var data KimaiData
+	err = json.Unmarshal(content, &data)
+	return data, err
+}
+
+var (
+	bucketMeta       = []byte("meta")
+	keySchemaVersion = []byte("schema_version")
+)
+
+// upgrades the stored rows to the current data model. Fetch snapshots are
+// left as they were taken and migrated when read.
+func (h *historyStore) migrate() error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return err
+		}
+		version := 1
+		if v := meta.Get(keySchemaVersion); v != nil {
+			version, _ = strconv.Atoi(string(v))
+		}
+		if version > dataSchemaVersion {
+			return fmt.Errorf("history written by a newer timo (schema version %d), please update", version)
+		}
+		if version == dataSchemaVersion {
+			return nil
+		}
+
+		for _, r := range sourceRegistry {
+			if tx.Bucket([]byte(sourceKey(r.name))) == nil {
+				continue
+			}
+			slog.Info("Migrating history", "source", r.name, "from", version, "to", dataSchemaVersion)
+			if r.kind == AttendanceSource {
+				err = migrateTimenetV1(tx, r.name)
+			} else {
+				err = migrateKimaiV1(tx, r.name)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %v", r.name, err)
+			}
+		}
+		return meta.Put(keySchemaVersion, []byte(strconv.Itoa(dataSchemaVersion)))
+	})
+}
+
+// replaces every row of a bucket with the converted key and value
+func rewriteBucket(b *bolt.Bucket, convert func(key string, value []byte) (string, any, error)) error {
+	type row struct {
+		key   string
+		value any
+	}
+	var rows []row
+	var oldKeys [][]byte
+	err := b.ForEach(func(k, v []byte) error {
+		key, value, err := convert(string(k), v)
+		if err != nil {
+			return fmt.Errorf("row %s: %v", k, err)
+		}
+		rows = append(rows, row{key, value})
+		oldKeys = append(oldKeys, append([]byte(nil), k...))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range oldKeys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	for _, r := range rows {
+		if err := putJSON(b, r.key, r.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateTimenetV1(tx *bolt.Tx, name string) error {
+	summary, err := sourceBucket(tx, name, bucketSummary)
+	if err != nil {
+		return err
+	}
+	err = rewriteBucket(summary, func(key string, value []byte) (string, any, error) {
+		var legacy timenetDataV1
+		err := json.Unmarshal(value, &legacy)
+		return key, legacy.migrate(), err
+	})
+	if err != nil {
+		return err
+	}
+
+	months, err := sourceBucket(tx, name, bucketMonths)
+	if err != nil {
+		return err
+	}
+	err = rewriteBucket(months, func(key string, value []byte) (string, any, error) {
+		var legacy timenetMonthV1
+		err := json.Unmarshal(value, &legacy)
+		month := legacy.migrate()
+		month.Month = key // already YYYY-MM
+		return key, month, err
+	})
+	if err != nil {
+		return err
+	}
+
+	days, err := sourceBucket(tx, name, bucketDays)
+	if err != nil {
+		return err
+	}
+	return rewriteBucket(days, func(key string, value []byte) (string, any, error) {
+		var legacy timenetDayV1
+		err := json.Unmarshal(value, &legacy)
+		return isoDateV1(key), legacy.migrate(), err
+	})
+}
+
+func migrateKimaiV1(tx *bolt.Tx, name string) error {
+	summary, err := sourceBucket(tx, name, bucketSummary)
+	if err != nil {
+		return err
+	}
+	err = rewriteBucket(summary, func(key string, value []byte) (string, any, error) {
+		var legacy kimaiDataV1
+		err := json.Unmarshal(value, &legacy)
+		return key, legacy.migrate(), err
+	})
+	if err != nil {
+		return err
+	}
+
+	months, err := sourceBucket(tx, name, bucketMonths)
+	if err != nil {
+		return err
+	}
+	err = rewriteBucket(months, func(key string, value []byte) (string, any, error) {
+		var fetchDate string
+		err := json.Unmarshal(value, &fetchDate)
+		return key, fetchedAtV1(fetchDate, "00:00"), err
+	})
+	if err != nil {
+		return err
+	}
+
+	entries, err := sourceBucket(tx, name, bucketEntries)
+	if err != nil {
+		return err
+	}
+	return rewriteBucket(entries, func(key string, value []byte) (string, any, error) {
+		var legacy kimaiEntryV1
+		err := json.Unmarshal(value, &legacy)
+		return isoDateV1(key), legacy.migrate(), err
+	})
+}
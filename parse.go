@@ -8,96 +8,288 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"timo/reconcile"
+
 	"github.com/PuerkitoBio/goquery"
 )
 
+// version of the stored data model, see migrate.go for older versions
+const dataSchemaVersion = 2
+
+// All times are stored as whole minutes, negative for time owed, dates in
+// ISO format (2025-03-04) and months as 2025-03. Formatting them for display
+// is up to the UI and the reports.
+
 // KIMAI JSON DATA STRUCTURE
 type KimaiData struct {
-	FetchDate   string             `json:"fetch_date"`
-	FetchTime   string             `json:"fetch_time"`
-	Summary     KimaiSummary       `json:"summary"`
-	MonthlyData []KimaiMonthlyData `json:"monthly_data"`
+	SchemaVersion int                `json:"schema_version"`
+	FetchedAt     time.Time          `json:"fetched_at"`
+	Summary       KimaiSummary       `json:"summary"`
+	MonthlyData   []KimaiMonthlyData `json:"monthly_data"`
+
+	// months in format YYYY-MM covered by the fetches, including months without entries
+	FetchedMonths []string `json:"fetched_months,omitempty"`
+
+	// problems found while parsing, checked before the data is stored
+	issues ParseDiagnostics
 }
 
 type KimaiSummary struct {
 	ReportingDateFrom string `json:"reporting_date_from"`
 	ReportingDateTo   string `json:"reporting_date_to"`
 	LoggedinUser      string `json:"loggedin_user"`
-	LoggedTime        string `json:"logged_time"`
+	LoggedMinutes     int    `json:"logged_minutes"`
 }
 
 type KimaiMonthlyData struct {
-	Date       string `json:"date"`
-	In         string `json:"in"`
-	Out        string `json:"out"`
-	WorkedTime string `json:"worked_time"`
-	Customer   string `json:"customer"`
-	Project    string `json:"project"`
-	Activity   string `json:"activity"`
-	Username   string `json:"username"`
+	ID            int     `json:"id,omitempty"` // Kimai timesheet ID, 0 when the page does not show it
+	Date          string  `json:"date"`
+	In            string  `json:"in"`  // clock time, 09:00
+	Out           string  `json:"out"` // blank while the entry is running
+	WorkedMinutes int     `json:"worked_minutes"`
+	Customer      string  `json:"customer"`
+	Project       string  `json:"project"`
+	Activity      string  `json:"activity"`
+	Username      string  `json:"username"`
+	Description   string  `json:"description,omitempty"`
+	Billable      bool    `json:"billable"`
+	HourlyRate    float64 `json:"hourly_rate,omitempty"`
+	Amount        float64 `json:"amount,omitempty"` // billed for the entry, in the currency of Kimai
 }
 
 // TIMENET JSON DATA STRUCTURE
 type TimenetData struct {
-	FetchDate                string               `json:"fetch_date"`
-	FetchTime                string               `json:"fetch_time"`
-	Year                     string               `json:"year"`
-	ExpectedWorkedTimeInYear string               `json:"expected_worked_time_in_year"`
-	WorkedTimeInYear         string               `json:"worked_time_in_year"`
-	OvertimeInYear           string               `json:"overtime_in_year"`
-	MonthlyData              []TimenetMonthlyData `json:"monthly_data"`
+	SchemaVersion         int                  `json:"schema_version"`
+	FetchedAt             time.Time            `json:"fetched_at"`
+	Year                  int                  `json:"year"`
+	ExpectedMinutesInYear int                  `json:"expected_minutes_in_year"`
+	WorkedMinutesInYear   int                  `json:"worked_minutes_in_year"`
+	OvertimeMinutesInYear int                  `json:"overtime_minutes_in_year"`
+	MonthlyData           []TimenetMonthlyData `json:"monthly_data"`
+
+	// problems found while parsing, checked before the data is stored
+	issues ParseDiagnostics
 }
 
 type TimenetMonthlyData struct {
-	Month                     string             `json:"month"`
-	ExpectedWorkedTimeInMonth string             `json:"expected_worked_time_in_month"`
-	WorkedTimeInMonth         string             `json:"worked_time_in_month"`
-	OvertimeInMonth           string             `json:"overtime_in_month"`
-	DailyData                 []TimenetDailyData `json:"daily_data"`
+	Month           string             `json:"month"` // YYYY-MM
+	ExpectedMinutes int                `json:"expected_minutes"`
+	WorkedMinutes   int                `json:"worked_minutes"`
+	OvertimeMinutes int                `json:"overtime_minutes"`
+	DailyData       []TimenetDailyData `json:"daily_data"`
 }
 
 type TimenetDailyData struct {
-	Date                    string `json:"date"`
-	ExpectedWorkedTimeInDay string `json:"expected_worked_time_in_day"`
-	WorkedTimeInDay         string `json:"worked_time_in_day"`
-	OvertimeInDay           string `json:"overtime_in_day"`
-	IsWorkDay               bool   `json:"is_work_day"`
-	IsHoliday               bool   `json:"is_holiday"`
-	IsVacation              bool   `json:"is_vacation"`
-	IsMedicalLeave          bool   `json:"is_medical_leave"`
-	IsCalendarAdjustment    bool   `json:"is_calendar_adjustment"`
-	IsWeekend               bool   `json:"is_weekend"`
+	Date            string  `json:"date"`
+	ExpectedMinutes int     `json:"expected_minutes"`
+	WorkedMinutes   int     `json:"worked_minutes"`
+	OvertimeMinutes int     `json:"overtime_minutes"`
+	DayType         DayType `json:"day_type"`
+	DayLabel        string  `json:"day_label"` // as shown by Timenet, e.g. "Festivo local"
+	Punches         []Punch `json:"punches,omitempty"`
+}
+
+// Punch is one clock-in and clock-out pair of a Timenet day, as clock times
+// like 09:00
+type Punch = reconcile.Punch
+
+// returns true when the last punch has no clock-out on a day already gone,
+// today it only means still at work
+func (d TimenetDailyData) missingClockOut() bool {
+	if len(d.Punches) == 0 || d.Punches[len(d.Punches)-1].Out != "" {
+		return false
+	}
+	return d.Date != time.Now().Format("2006-01-02")
+}
+
+// returns the minutes between the clock-in and clock-out of complete punches
+func punchedMinutes(punches []Punch) int {
+	minutes := 0
+	for _, p := range punches {
+		in, err1 := time.Parse("15:04", p.In)
+		out, err2 := time.Parse("15:04", p.Out)
+		if err1 == nil && err2 == nil && out.After(in) {
+			minutes += int(out.Sub(in).Minutes())
+		}
+	}
+	return minutes
+}
+
+// converts a cell like "9h 14m" or "-30m" to minutes. A blank cell is 0,
+// or an error when required. Errors are recorded in issues, not returned,
+// so that the parser reads the whole page and all problems are reported.
+func parseCellMinutes(issues *ParseDiagnostics, where, field, value string, required bool) int {
+	if value == "" {
+		if required {
+			issues.add(IssueError, "", where, "blank %s", field)
+		}
+		return 0
+	}
+	minutes, err := convertTimeStringToMinutes(value)
+	if err != nil {
+		issues.add(IssueError, "", where, "%s '%s' is not a time", field, value)
+		return 0
+	}
+	return minutes
+}
+
+// converts a Kimai duration like "4:30:00" to minutes, recording errors in issues
+func parseHMSMinutes(issues *ParseDiagnostics, where, field, value string) int {
+	if value == "" {
+		issues.add(IssueError, "", where, "blank %s", field)
+		return 0
+	}
+	minutes, err := convertTimeStringToMinutes(formatTimeFromHMS(value))
+	if err != nil {
+		issues.add(IssueError, "", where, "%s '%s' is not a time", field, value)
+		return 0
+	}
+	return minutes
+}
+
+// converts a Kimai clock time like "09:00:00" to "09:00", blank stays blank
+func parseClockTime(issues *ParseDiagnostics, where, field, value string) string {
+	if value == "" {
+		return ""
+	}
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("15:04")
+		}
+	}
+	issues.add(IssueError, "", where, "%s '%s' is not a clock time", field, value)
+	return ""
+}
+
+// the number in a Kimai row ID, and what is not the year in a month header
+var (
+	digitsRe   = regexp.MustCompile(`\d+`)
+	notDigitRe = regexp.MustCompile(`[^0-9]`)
+)
+
+// a clock time in a punch cell, the time may come with a note, like "08:58 (E)"
+var punchTimeRe = regexp.MustCompile(`\d{1,2}:\d{2}`)
+
+// pairs the punch times of a day in order, clock-in then clock-out. An odd
+// number of times leaves the last punch without clock-out.
+func parsePunches(issues *ParseDiagnostics, where string, times []string) []Punch {
+	var punches []Punch
+	n := 0
+	for _, value := range times {
+		if value == "" {
+			continue
+		}
+		match := punchTimeRe.FindString(value)
+		if match == "" {
+			issues.add(IssueError, "", where, "punch '%s' is not a clock time", value)
+			continue
+		}
+		clock := parseClockTime(issues, where, "punch", match)
+		if clock == "" {
+			continue
+		}
+		if n%2 == 0 {
+			punches = append(punches, Punch{In: clock})
+		} else {
+			punches[len(punches)-1].Out = clock
+		}
+		n++
+	}
+	return punches
+}
+
+// anything but the digits, separators and sign of an amount
+var notAmountRe = regexp.MustCompile(`[^0-9,.\-]`)
+
+// converts an amount like "45,00 €" or "1,234.50" to a number. The last
+// separator is the decimal one, unless three digits follow it as in "1.234",
+// which is only read as thousands when more separators or a whole part other
+// than zero come before. "0.125" is read as decimals with a warning. Blank is 0.
+func parseAmount(issues *ParseDiagnostics, where, field, value string) float64 {
+	value = notAmountRe.ReplaceAllString(value, "")
+	if value == "" {
+		return 0
+	}
+	raw := value
+	if i := strings.LastIndexAny(value, ",."); i >= 0 {
+		integer := strings.NewReplacer(",", "", ".", "").Replace(value[:i])
+		decimals := value[i+1:]
+		thousands := strings.ContainsAny(value[:i], ",.") || strings.Trim(integer, "-0") != ""
+		if len(decimals) == 3 && thousands {
+			value = integer + decimals
+		} else {
+			if len(decimals) == 3 {
+				issues.add(IssueWarning, "", where, "%s '%s' is ambiguous, read as %s.%s", field, raw, integer, decimals)
+			}
+			value = integer + "." + decimals
+		}
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		issues.add(IssueWarning, "", where, "%s '%s' is not an amount", field, raw)
+		return 0
+	}
+	return amount
+}
+
+// reads a billable cell, "100%", "0%", "yes" or "no". Kimai bills entries
+// unless told otherwise, so a blank or missing cell is billable.
+func parseBillable(value string) bool {
+	switch strings.ToLower(strings.TrimSuffix(strings.TrimSpace(value), "%")) {
+	case "0", "no", "false", "✗":
+		return false
+	}
+	return true
 }
 
-// timenetParse extracts data from Timenet HTML and saves to JSON file
-func timenetParse(htmlContent *string) error {
+// removes the entries whose ID was already seen, pages may overlap when
+// entries are added while fetching
+func dedupeKimaiEntries(entries []KimaiMonthlyData) ([]KimaiMonthlyData, int) {
+	seen := make(map[int]bool)
+	var unique []KimaiMonthlyData
+	for _, entry := range entries {
+		if entry.ID != 0 && seen[entry.ID] {
+			continue
+		}
+		seen[entry.ID] = true
+		unique = append(unique, entry)
+	}
+	return unique, len(entries) - len(unique)
+}
+
+// timenetParse extracts data from Timenet HTML
+func timenetParse(htmlContent *string) (TimenetData, error) {
 	if htmlContent == nil {
-		return fmt.Errorf("HTML content is nil")
+		return TimenetData{}, fmt.Errorf("HTML content is nil")
 	}
 
 	data := TimenetData{
-		FetchDate: time.Now().Format("2006/01/02"),
-		FetchTime: time.Now().Format("15:04"),
+		SchemaVersion: dataSchemaVersion,
+		FetchedAt:     time.Now(),
 	}
 
 	// NewDocumentFromReader takes a io.Reader not a string
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(*htmlContent))
 	if err != nil {
-		return err
+		return data, err
 	}
 
+	sel := config.Timenet.Selectors
+
 	// REVIEW THESE 3 ITEMS
-	data.ExpectedWorkedTimeInYear = strings.TrimSpace(doc.Find("table.table-resum-hores tbody tr").First().Find("td").Eq(2).Text())
-	data.OvertimeInYear = strings.TrimSpace(doc.Find("table.table-resum-hores tbody tr").Eq(2).Find("td").Eq(2).Text())
-	data.WorkedTimeInYear = strings.TrimSpace(doc.Find("table.table-resum-hores tbody tr").Eq(1).Find("td").Eq(2).Text())
+	yearTotals := doc.Find(sel.Totals)
+	data.ExpectedMinutesInYear = parseCellMinutes(&data.issues, "", "yearly expected time", strings.TrimSpace(yearTotals.First().Find("td").Eq(2).Text()), false)
+	data.OvertimeMinutesInYear = parseCellMinutes(&data.issues, "", "yearly overtime", strings.TrimSpace(yearTotals.Eq(2).Find("td").Eq(2).Text()), false)
+	data.WorkedMinutesInYear = parseCellMinutes(&data.issues, "", "yearly worked time", strings.TrimSpace(yearTotals.Eq(1).Find("td").Eq(2).Text()), false)
 
-	str := strings.TrimSpace(doc.Find(".container-mes-checks h2").First().Text()) // taken from current month
-	data.Year = regexp.MustCompile(`[^0-9]`).ReplaceAllString(str, "")            // get only the year number
+	str := strings.TrimSpace(doc.Find(sel.MonthHeader + " h2").First().Text()) // taken from current month
+	data.Year, _ = strconv.Atoi(notDigitRe.ReplaceAllString(str, ""))          // get only the year number
 
-	monthlyEntries := doc.Find("div.card")
+	monthlyEntries := doc.Find(sel.MonthCard)
 	slog.Info("Timenet: Number of months to parse", "count", monthlyEntries.Length())
 
 	monthlyEntries.Each(func(i int, s *goquery.Selection) {
@@ -105,56 +297,56 @@ func timenetParse(htmlContent *string) error {
 		// let's create one month of data
 		monthlyData := TimenetMonthlyData{}
 
-		str := strings.TrimSpace(s.Find(".container-mes-checks h2").First().Text())
-		monthlyData.Month = GetMonth(str) // convert Spanish string to English month
+		str := strings.TrimSpace(s.Find(sel.MonthHeader + " h2").First().Text())
+		if month, err := parseMonthHeader(str); err == nil {
+			monthlyData.Month = month.Format("2006-01")
+		} else {
+			data.issues.add(IssueError, "", "", "unknown month name '%s'", str)
+		}
 
-		monthlyData.ExpectedWorkedTimeInMonth = strings.TrimSpace(s.Find("table.table-resum-hores tbody tr").First().Find("td").Eq(1).Text())
-		monthlyData.WorkedTimeInMonth = strings.TrimSpace(s.Find("table.table-resum-hores tbody tr").Eq(1).Find("td").Eq(1).Text())
-		monthlyData.OvertimeInMonth = strings.TrimSpace(s.Find("table.table-resum-hores tbody tr").Eq(2).Find("td").Eq(1).Text())
+		where := monthlyData.Month
+		totals := s.Find(sel.Totals)
+		monthlyData.ExpectedMinutes = parseCellMinutes(&data.issues, where, "expected time", strings.TrimSpace(totals.First().Find("td").Eq(1).Text()), true)
+		monthlyData.WorkedMinutes = parseCellMinutes(&data.issues, where, "worked time", strings.TrimSpace(totals.Eq(1).Find("td").Eq(1).Text()), true)
+		monthlyData.OvertimeMinutes = parseCellMinutes(&data.issues, where, "overtime", strings.TrimSpace(totals.Eq(2).Find("td").Eq(1).Text()), true)
 
 		// let's fill up each day of data in one month
-		dailyEntries := s.Find("table.table-checks tbody tr")
+		dailyEntries := s.Find(sel.DayRows)
 		slog.Info("Timenet: Number of days to parse", "count", dailyEntries.Length())
 
 		dailyEntries.Each(func(i int, content *goquery.Selection) {
 			dailyData := TimenetDailyData{}
 
-			// store data in format YYYY/MM/DD
-			dailyData.Date = convertDateFormat(strings.TrimSpace(content.Find(".day-value").Text()))
-
-			dailyData.ExpectedWorkedTimeInDay = strings.TrimSpace(content.Find(".prevision-day-check").Text())
-			dailyData.WorkedTimeInDay = strings.TrimSpace(content.Find(".total-day-check span").Text())
-			dailyData.OvertimeInDay = strings.TrimSpace(content.Find(".diff-day-check span").Text())
-
-			dayTypeName := strings.TrimSpace(content.Find(".day-type-name").Text())
-
-			dailyData.IsWorkDay = dailyData.ExpectedWorkedTimeInDay != "" ||
-				strings.Contains(dayTypeName, "Laborable")
-
-			dailyData.IsHoliday = strings.Contains(dayTypeName, "Festivo") ||
-				strings.Contains(dayTypeName, "Bank Holiday")
-
-			dailyData.IsVacation = strings.Contains(dayTypeName, "Vacation") ||
-				strings.Contains(dayTypeName, "Vacaciones")
-
-			dailyData.IsMedicalLeave = strings.Contains(dayTypeName, "baja con parte médico") ||
-				strings.Contains(dayTypeName, "Weekend while on IT leave") ||
-				strings.Contains(dayTypeName, "Seek time")
-
-			dailyData.IsCalendarAdjustment = strings.Contains(dayTypeName, "calendar adjustment")
+			// store data in format YYYY-MM-DD, rows without date like the
+			// totals are not days
+			dailyData.Date = convertDateFormat(strings.TrimSpace(content.Find(sel.DayDate).Text()))
+			if dailyData.Date == "" {
+				return
+			}
 
-			dailyData.IsWeekend = strings.Contains(dayTypeName, "non working day") // Saturday or Sunday
+			expected := strings.TrimSpace(content.Find(sel.DayExpected).Text())
+			dailyData.ExpectedMinutes = parseCellMinutes(&data.issues, dailyData.Date, "expected time", expected, false)
+			dailyData.WorkedMinutes = parseCellMinutes(&data.issues, dailyData.Date, "worked time", strings.TrimSpace(content.Find(sel.DayWorked).Text()), false)
+			dailyData.OvertimeMinutes = parseCellMinutes(&data.issues, dailyData.Date, "overtime", strings.TrimSpace(content.Find(sel.DayOvertime).Text()), false)
 
-			// Only add if we have a valid date
-			if dailyData.Date != "" {
-				monthlyData.DailyData = append(monthlyData.DailyData, dailyData)
-				//slog.Info("Timenet: Parsed daily data for", "date", dailyData.Date)
+			dailyData.DayLabel = strings.TrimSpace(content.Find(sel.DayLabel).Text())
+			dayType, found := classifyDayType(dailyData.DayLabel, expected != "")
+			if !found && dailyData.DayLabel != "" {
+				data.issues.add(IssueWarning, "", dailyData.Date, "unknown day type '%s', add it to day_types in the config", dailyData.DayLabel)
 			}
+			dailyData.DayType = dayType
+
+			var times []string
+			content.Find(sel.Punches).Each(func(i int, punch *goquery.Selection) {
+				times = append(times, strings.TrimSpace(punch.Text()))
+			})
+			dailyData.Punches = parsePunches(&data.issues, dailyData.Date, times)
 
+			monthlyData.DailyData = append(monthlyData.DailyData, dailyData)
 		})
 
 		// let's fill up each day of data in one month
-		monthlyRows := doc.Find("table.table-checks tbody tr")
+		monthlyRows := doc.Find(sel.DayRows)
 		slog.Info("Timenet: Found and extracting daily rows", "count", monthlyRows.Length())
 
 		// let's add the monthly data
@@ -163,15 +355,7 @@ func timenetParse(htmlContent *string) error {
 
 	})
 
-	// Save to JSON file
-	filename := fmt.Sprintf("timenet_data_%s.json", time.Now().Format("2006-01-02"))
-	err = saveToJSON(data, filename)
-	if err != nil {
-		return fmt.Errorf("failed to save JSON: %v", err)
-	}
-
-	slog.Info("Timenet data saved", "filename", filename)
-	return nil
+	return data, nil
 }
 
 // Save data to a JSON file in the OS temp folder
@@ -245,55 +429,69 @@ func cleanHTML(html *string) {
 	*html = styleAttrRe.ReplaceAllString(*html, "")
 }
 
-// extracts data from Kimai HTML and saves to JSON file
-func kimaiParse(htmlContent *string) error {
+// extracts data from Kimai HTML
+func kimaiParse(htmlContent *string) (KimaiData, error) {
 	if htmlContent == nil {
-		return fmt.Errorf("HTML content is nil")
+		return KimaiData{}, fmt.Errorf("HTML content is nil")
 	}
 
 	data := KimaiData{
-		FetchDate: time.Now().Format("2006/01/02"),
-		FetchTime: time.Now().Format("15:04"),
+		SchemaVersion: dataSchemaVersion,
+		FetchedAt:     time.Now(),
 	}
 
 	// NewDocumentFromReader takes a io.Reader not a string
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(*htmlContent))
 	if err != nil {
-		return err
+		return data, err
 	}
 
-	data.Summary.LoggedinUser = strings.TrimSpace(doc.Find("#top #menu b").First().Text())
+	sel := config.Kimai.Selectors
+	col := sel.Columns
 
-	// Extract summary data
-	// TODO these dates are not the right format FIXIT
-	data.Summary.ReportingDateFrom = doc.Find("#pick_in").AttrOr("value", "")
-	data.Summary.ReportingDateTo = doc.Find("#pick_out").AttrOr("value", "")
-	data.Summary.LoggedTime = formatTimeFromHMS(strings.TrimSpace(doc.Find("#display_total").Text()))
+	data.Summary.LoggedinUser = strings.TrimSpace(doc.Find(sel.LoggedinUser).First().Text())
+
+	// Extract summary data, dates are shown as DD/MM/YYYY
+	data.Summary.ReportingDateFrom = convertDateFormat(doc.Find(sel.FromInput).AttrOr("value", ""))
+	data.Summary.ReportingDateTo = convertDateFormat(doc.Find(sel.ToInput).AttrOr("value", ""))
+	total := strings.TrimSpace(doc.Find(sel.Total).Text())
+	if total != "" {
+		data.Summary.LoggedMinutes = parseHMSMinutes(&data.issues, "", "logged time total", total)
+	}
 
 	// Extract monthly data from timesheet entries
-	monthlyRows := doc.Find("#timeSheetTable table tbody tr")
+	monthlyRows := doc.Find(sel.TimeSheetTable + " table tbody tr")
 	slog.Info("Kimai: Found and extracting timesheet rows: ", "count", monthlyRows.Length())
 
 	monthlyRows.Each(func(i int, row *goquery.Selection) {
+		// rows without date, like the totals, are not entries
+		dateText := strings.TrimSpace(row.Find(col.Date).Text())
+		if dateText == "" {
+			return
+		}
+
 		monthlyData := KimaiMonthlyData{}
 
-		// Extract date and convert it in format YYYY/MM/DD)
-		dateText := strings.TrimSpace(row.Find("td.date").Text())
+		// Kimai 1 sets the entry ID in the row, e.g. id="timeSheetEntry123"
+		rowID := row.AttrOr("data-id", row.AttrOr("id", ""))
+		monthlyData.ID, _ = strconv.Atoi(digitsRe.FindString(rowID))
+
+		// Extract date and convert it in format YYYY-MM-DD
 		monthlyData.Date = convertDateFormat(dateText)
+		where := monthlyData.Date
 
-		// Extract in/out times and convert to Xh Ym format
-		monthlyData.In = formatTimeFromHMS(strings.TrimSpace(row.Find("td.from").Text()))
-		monthlyData.Out = formatTimeFromHMS(strings.TrimSpace(row.Find("td.to").Text()))
+		// Extract in/out times as clock times
+		monthlyData.In = parseClockTime(&data.issues, where, "start time", strings.TrimSpace(row.Find(col.From).Text()))
+		monthlyData.Out = parseClockTime(&data.issues, where, "end time", strings.TrimSpace(row.Find(col.To).Text()))
 
-		// Extract worked time (format H:MM:SS) and convert to Xh Ym format
-		workedTimeRaw := strings.TrimSpace(row.Find("td.time").Text())
-		monthlyData.WorkedTime = formatTimeFromHMS(workedTimeRaw)
+		// Extract worked time (format H:MM:SS) in minutes
+		monthlyData.WorkedMinutes = parseHMSMinutes(&data.issues, where, "worked time", strings.TrimSpace(row.Find(col.Time).Text()))
 
 		// Extract customer name
-		monthlyData.Customer = strings.TrimSpace(row.Find("td.customer").Text())
+		monthlyData.Customer = strings.TrimSpace(row.Find(col.Customer).Text())
 
 		// Extract project name (may be inside a link)
-		projectCell := row.Find("td.project")
+		projectCell := row.Find(col.Project)
 		projectLink := projectCell.Find("a")
 		if projectLink.Length() > 0 {
 			monthlyData.Project = strings.TrimSpace(projectLink.Text())
@@ -302,7 +500,7 @@ func kimaiParse(htmlContent *string) error {
 		}
 
 		// Extract activity name (may be inside a link)
-		activityCell := row.Find("td.activity")
+		activityCell := row.Find(col.Activity)
 		activityLink := activityCell.Find("a")
 		if activityLink.Length() > 0 {
 			monthlyData.Activity = strings.TrimSpace(activityLink.Text())
@@ -311,23 +509,26 @@ func kimaiParse(htmlContent *string) error {
 		}
 
 		// extras username if available
-		monthlyData.Username = strings.TrimSpace(row.Find("td.username").Text())
+		monthlyData.Username = strings.TrimSpace(row.Find(col.Username).Text())
 
-		// Only add if we have a valid date
-		if monthlyData.Date != "" && dateText != "" {
-			data.MonthlyData = append(data.MonthlyData, monthlyData)
-		}
+		// billing columns, shown depending on the user rights
+		monthlyData.Description = strings.TrimSpace(row.Find(col.Description).Text())
+		monthlyData.Billable = parseBillable(row.Find(col.Billable).Text())
+		monthlyData.HourlyRate = parseAmount(&data.issues, where, "hourly rate", row.Find(col.Rate).Text())
+		monthlyData.Amount = parseAmount(&data.issues, where, "amount", row.Find(col.Amount).Text())
+
+		data.MonthlyData = append(data.MonthlyData, monthlyData)
 	})
 
-	// Save to JSON file
-	filename := fmt.Sprintf("kimai_data_%s.json", time.Now().Format("2006-01-02"))
-	err = saveToJSON(data, filename)
-	if err != nil {
-		return fmt.Errorf("failed to save JSON: %v", err)
+	var dupes int
+	if data.MonthlyData, dupes = dedupeKimaiEntries(data.MonthlyData); dupes > 0 {
+		slog.Info("Kimai: Skipped entries found twice", "count", dupes)
 	}
 
-	slog.Info("Kimai data saved", "filename", filename)
-	return nil
+	if total == "" && len(data.MonthlyData) > 0 {
+		data.issues.add(IssueWarning, "", "", "blank logged time total")
+	}
+	return data, nil
 }
 
 func testKimaiParsing() {
@@ -342,15 +543,26 @@ func testKimaiParsing() {
 
 	// Test the kimai parsing
 	fmt.Println("Testing Kimai HTML parsing...")
-	err = kimaiParse(&htmlString)
+	data, err := kimaiParse(&htmlString)
 	if err != nil {
 		log.Fatal("Error parsing Kimai data:", err)
 	}
+	err = saveToJSON(data, fmt.Sprintf("kimai_data_%s.json", time.Now().Format("2006-01-02")))
+	if err != nil {
+		log.Fatal("Error saving Kimai data:", err)
+	}
 
 	fmt.Println("Kimai parsing completed successfully! Check the JSON file in temp directory.")
 }
 
 // converts Spanish month names to English (case-insensitive)
+// converts the Spanish month name of a Timenet month header, e.g.
+// "Marzo 2025", to the first day of that month
+func parseMonthHeader(header string) (time.Time, error) {
+	year := notDigitRe.ReplaceAllString(header, "")
+	return time.ParseInLocation("January 2006", GetMonth(header)+" "+year, time.Local)
+}
+
 func GetMonth(input string) string {
 	monthMap := map[string]string{
 		"enero": "January", "febrero": "February", "marzo": "March", "abril": "April",
@@ -379,10 +591,14 @@ func testTimenetParsing() {
 
 	// Test the timenet parsing
 	fmt.Println("Testing Timenet HTML parsing...")
-	err = timenetParse(&htmlString)
+	data, err := timenetParse(&htmlString)
 	if err != nil {
 		log.Fatal("Error parsing Timenet data:", err)
 	}
+	err = saveToJSON(data, fmt.Sprintf("timenet_data_%s.json", time.Now().Format("2006-01-02")))
+	if err != nil {
+		log.Fatal("Error saving Timenet data:", err)
+	}
 
 	fmt.Println("Parsing completed successfully! Check the JSON file in temp directory.")
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// FETCH PROGRESS
+// The scrapers and fetchSource report each step of a fetch through the
+// context, the UI turns them into one progress bar per source. Without a
+// reporter in the context, as in the CLI, nothing is reported.
+
+// fetchStage is the step a fetch has reached
+type fetchStage int
+
+const (
+	stageStarted  fetchStage = iota
+	stageLoggedIn            // login done or session restored
+	stageMonth               // Month of Months captured
+	stageParsing
+	stageSaved
+)
+
+// fetchProgress is one step of the fetch of a source
+type fetchProgress struct {
+	Source string
+	Stage  fetchStage
+	Month  int // months captured, for stageMonth
+	Months int // months in the fetch range
+}
+
+type progressKey struct{}
+
+// returns a context whose fetches call report on every step, report must not block
+func withProgress(ctx context.Context, report func(fetchProgress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+// sets the source and the months of the range in the steps reported with ctx
+func withProgressSource(ctx context.Context, source string, months int) context.Context {
+	report, ok := ctx.Value(progressKey{}).(func(fetchProgress))
+	if !ok {
+		return ctx
+	}
+	return withProgress(ctx, func(p fetchProgress) {
+		p.Source = source
+		if p.Months == 0 {
+			p.Months = months
+		}
+		report(p)
+	})
+}
+
+// reports a step of the fetch running with ctx, if anyone listens
+func reportProgress(ctx context.Context, p fetchProgress) {
+	if report, ok := ctx.Value(progressKey{}).(func(fetchProgress)); ok {
+		report(p)
+	}
+}
+
+// returns the part of the fetch done, from 0 to 1: the login, each month,
+// the parsing and the saving are one step each
+func (p fetchProgress) done() float64 {
+	steps := float64(p.Months + 3)
+	switch p.Stage {
+	case stageLoggedIn:
+		return 1 / steps
+	case stageMonth:
+		return float64(1+min(p.Month, p.Months)) / steps
+	case stageParsing:
+		return float64(p.Months+1) / steps
+	case stageSaved:
+		return 1
+	}
+	return 0
+}
+
+func (p fetchProgress) String() string {
+	switch p.Stage {
+	case stageLoggedIn:
+		return "logged in"
+	case stageMonth:
+		return fmt.Sprintf("month %d/%d captured", p.Month, p.Months)
+	case stageParsing:
+		return "parsing"
+	case stageSaved:
+		return "saved"
+	}
+	return "logging in"
+}
+
+// returns a bar of width characters filled up to the part done
+func progressBar(done float64, width int) string {
+	filled := int(done*float64(width) + 0.5)
+	filled = max(0, min(filled, width))
+	return focusedStyle.Render(strings.Repeat("█", filled)) + blurredStyle.Render(strings.Repeat("░", width-filled))
+}
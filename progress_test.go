@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestFetchProgress reports the steps of a fetch through a context
+func TestFetchProgress(t *testing.T) {
+	var reported []fetchProgress
+	ctx := withProgressSource(withProgress(context.Background(), func(p fetchProgress) {
+		reported = append(reported, p)
+	}), "Timenet", 9)
+	reportProgress(ctx, fetchProgress{Stage: stageLoggedIn})
+	reportProgress(ctx, fetchProgress{Stage: stageMonth, Month: 3})
+	reportProgress(ctx, fetchProgress{Stage: stageSaved})
+	reportProgress(context.Background(), fetchProgress{Stage: stageParsing})
+
+	if len(reported) != 3 {
+		t.Fatalf("got %d steps reported, expected 3", len(reported))
+	}
+	if reported[1].Source != "Timenet" || reported[1].Months != 9 {
+		t.Errorf("got source '%s' and %d months, expected Timenet and 9", reported[1].Source, reported[1].Months)
+	}
+	if got := reported[0].String(); got != "logged in" {
+		t.Errorf("got label '%s', expected 'logged in'", got)
+	}
+	if got := reported[1].String(); got != "month 3/9 captured" {
+		t.Errorf("got label '%s', expected 'month 3/9 captured'", got)
+	}
+
+	if done := (fetchProgress{Months: 9}).done(); done != 0 {
+		t.Errorf("started: got %v done, expected 0", done)
+	}
+	if done := reported[0].done(); done != 1.0/12 {
+		t.Errorf("logged in: got %v done, expected %v", done, 1.0/12)
+	}
+	if done := reported[1].done(); done != 4.0/12 {
+		t.Errorf("month 3: got %v done, expected %v", done, 4.0/12)
+	}
+	if done := (fetchProgress{Stage: stageParsing, Months: 9}).done(); done != 10.0/12 {
+		t.Errorf("parsing: got %v done, expected %v", done, 10.0/12)
+	}
+	if done := reported[2].done(); done != 1 {
+		t.Errorf("saved: got %v done, expected 1", done)
+	}
+
+	bar := progressBar(reported[1].done(), 12)
+	if filled, empty := strings.Count(bar, "█"), strings.Count(bar, "░"); filled != 4 || empty != 8 {
+		t.Errorf("got %d filled and %d empty in the bar, expected 4 and 8", filled, empty)
+	}
+
+	// the UI keeps the last step of each running fetch and drops the others
+	m := newModel(defaultFetchRange())
+	m.pendingFetches["Timenet"] = true
+	m.progress, m.progressDone = make(chan fetchProgress), make(chan struct{})
+	updated, _ := m.Update(progressMsg(reported[1]))
+	updated, _ = updated.Update(progressMsg{Source: "Kimai", Stage: stageSaved})
+	view := updated.(model).progressView()
+	if !strings.Contains(view, "Timenet") || !strings.Contains(view, "month 3/9 captured") {
+		t.Errorf("Timenet bar not shown in '%s'", view)
+	}
+	if strings.Contains(view, "Kimai") {
+		t.Errorf("bar of a fetch not running shown in '%s'", view)
+	}
+	if !strings.Contains(view, "elapsed") {
+		t.Errorf("elapsed time not shown in '%s'", view)
+	}
+
+	updated, _ = updated.Update(fetchMsg{success: true, message: "Timenet fetch completed successfully", source: "Timenet"})
+	finished := updated.(model)
+	if view := finished.progressView(); view != "" {
+		t.Errorf("got '%s' when done, expected no bars", view)
+	}
+	if finished.progress != nil {
+		t.Error("progress not stopped when done")
+	}
+}
@@ -0,0 +1,169 @@
+// Package reconcile compares the days worked in Timenet with the entries
+// booked in Kimai. It knows nothing about scraping or storage, the caller
+// converts its data to TimenetMonth and Entry first.
+package reconcile
+
+// flags raised on a reconciled day
+const (
+	FlagLargeDiff = "large_diff" // Kimai and Timenet differ by one hour or more
+	FlagNoKimai   = "no_kimai"   // worked in Timenet but nothing booked in Kimai
+	FlagNoTimenet = "no_timenet" // booked in Kimai but nothing worked in Timenet
+
+	FlagMissingClockOut = "missing_clock_out" // the last Timenet punch has no clock-out
+)
+
+// Punch is a clock-in and its clock-out
+type Punch struct {
+	In  string `json:"in"`
+	Out string `json:"out"` // blank when the clock-out is missing
+}
+
+// TimenetDay is one Timenet day, all times are in minutes
+type TimenetDay struct {
+	Date            string // YYYY-MM-DD
+	DayType         string
+	DayLabel        string
+	Expected        int
+	Worked          int
+	Overtime        int
+	Punches         []Punch
+	MissingClockOut bool // the last punch has no clock-out on a day already gone
+}
+
+// TimenetMonth is one Timenet month, totals are in minutes
+type TimenetMonth struct {
+	Month    string // YYYY-MM
+	Expected int
+	Worked   int
+	Days     []TimenetDay
+}
+
+// Entry is one Kimai entry, classified by the rules
+type Entry struct {
+	Date     string // YYYY-MM-DD
+	Minutes  int
+	Customer string
+	Project  string
+	Activity string
+	Username string
+}
+
+// DayResult is one Timenet day compared with the Kimai entries of that day,
+// all times are in minutes
+type DayResult struct {
+	Date     string   `json:"date"` // YYYY-MM-DD
+	DayType  string   `json:"day_type"`
+	DayLabel string   `json:"day_label,omitempty"` // as shown by Timenet
+	Expected int      `json:"expected"`
+	Worked   int      `json:"worked"` // Timenet
+	Overtime int      `json:"overtime"`
+	Kimai    int      `json:"kimai"` // Kimai work entries only
+	Break    int      `json:"break"`
+	Absence  int      `json:"absence"`
+	Diff     int      `json:"diff"`    // Kimai minus Timenet
+	Warning  bool     `json:"warning"` // shown with ⚡ in the summary
+	Flags    []string `json:"flags,omitempty"`
+	Punches  []Punch  `json:"punches,omitempty"`
+}
+
+// MonthResult is one Timenet month compared with Kimai, totals are in minutes
+type MonthResult struct {
+	Month           string      `json:"month"` // YYYY-MM
+	ExpectedInMonth int         `json:"expected_in_month"`
+	WorkedInMonth   int         `json:"worked_in_month"`
+	Expected        int         `json:"expected"`
+	Worked          int         `json:"worked"`
+	Overtime        int         `json:"overtime"`
+	Kimai           int         `json:"kimai"`
+	Break           int         `json:"break"`
+	Absence         int         `json:"absence"`
+	Diff            int         `json:"diff"`
+	Days            []DayResult `json:"days"`
+}
+
+// returns true if the day has the given flag
+func (d DayResult) HasFlag(flag string) bool {
+	for _, f := range d.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// Reconcile compares one Timenet month with the Kimai entries, classified
+// with the given rules. Entries of other months are ignored.
+func Reconcile(month TimenetMonth, entries []Entry, rules Rules) MonthResult {
+	result := MonthResult{
+		Month:           month.Month,
+		ExpectedInMonth: month.Expected,
+		WorkedInMonth:   month.Worked,
+	}
+
+	// index entries by date once instead of scanning them for every day
+	byDate := make(map[string][]Entry)
+	for _, entry := range entries {
+		byDate[entry.Date] = append(byDate[entry.Date], entry)
+	}
+
+	for _, day := range month.Days {
+		dayResult := DayResult{
+			Date:     day.Date,
+			DayType:  day.DayType,
+			DayLabel: day.DayLabel,
+			Punches:  day.Punches,
+			Expected: day.Expected,
+			Worked:   day.Worked,
+			Overtime: day.Overtime,
+		}
+
+		// there might be more than one entry for the same day, so we sum them up
+		for _, entry := range byDate[day.Date] {
+			minutes := entry.Minutes
+			switch rules.Classify(entry) {
+			case ClassWork:
+				dayResult.Kimai += minutes
+			case ClassBreak:
+				dayResult.Break += minutes
+			case ClassAbsence:
+				dayResult.Absence += minutes
+			}
+		}
+
+		// TODO is this correct when it is a Flexitime day?
+		dayResult.Diff = dayResult.Kimai - dayResult.Worked
+		if dayResult.Diff > 59 || dayResult.Diff < -59 {
+			dayResult.Flags = append(dayResult.Flags, FlagLargeDiff)
+			dayResult.Warning = true
+		}
+		if dayResult.Worked > 0 && dayResult.Kimai == 0 {
+			dayResult.Flags = append(dayResult.Flags, FlagNoKimai)
+		}
+		if dayResult.Worked == 0 && dayResult.Kimai > 0 {
+			dayResult.Flags = append(dayResult.Flags, FlagNoTimenet)
+		}
+		if day.MissingClockOut {
+			dayResult.Flags = append(dayResult.Flags, FlagMissingClockOut)
+		}
+
+		result.Expected += dayResult.Expected
+		result.Worked += dayResult.Worked
+		result.Overtime += dayResult.Overtime
+		result.Kimai += dayResult.Kimai
+		result.Break += dayResult.Break
+		result.Absence += dayResult.Absence
+		result.Diff += dayResult.Diff
+		result.Days = append(result.Days, dayResult)
+	}
+
+	return result
+}
+
+// ReconcileAll compares every Timenet month with Kimai, newest month first
+func ReconcileAll(months []TimenetMonth, entries []Entry, rules Rules) []MonthResult {
+	var results []MonthResult
+	for _, month := range months {
+		results = append(results, Reconcile(month, entries, rules))
+	}
+	return results
+}
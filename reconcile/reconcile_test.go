@@ -0,0 +1,185 @@
+package reconcile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	month := TimenetMonth{
+		Month:    "2025-03",
+		Expected: 960,
+		Worked:   930,
+		Days: []TimenetDay{
+			{Date: "2025-03-03", Expected: 480, Worked: 480, DayType: "work_day"},
+			{Date: "2025-03-04", Expected: 480, Worked: 450, Overtime: -30, DayType: "work_day",
+				Punches: []Punch{{In: "09:00", Out: "13:00"}, {In: "13:30"}}, MissingClockOut: true},
+			{Date: "2025-03-05", DayType: "holiday"},
+			{Date: "2025-03-08", DayType: "weekend"},
+		},
+	}
+	entries := []Entry{
+		{Date: "2025-03-03", Minutes: 360, Project: "Timo", Activity: "Development"},
+		{Date: "2025-03-03", Minutes: 120, Project: "Timo", Activity: "Review"},
+		{Date: "2025-03-03", Minutes: 45, Project: "Break", Activity: "Lunch"},
+		{Date: "2025-03-05", Minutes: 480, Project: "Internal", Activity: "Public Holiday"},
+		{Date: "2025-03-08", Minutes: 60, Project: "Timo", Activity: "Hotfix"},
+		{Date: "2025-04-01", Minutes: 480, Project: "Timo", Activity: "Development"},
+	}
+
+	result := Reconcile(month, entries, DefaultRules())
+	if len(result.Days) != 4 {
+		t.Fatalf("got %d days, expected 4", len(result.Days))
+	}
+
+	if result.Month != "2025-03" || result.ExpectedInMonth != 960 || result.WorkedInMonth != 930 {
+		t.Errorf("got month %s with %d expected and %d worked, expected 2025-03 with 960 and 930",
+			result.Month, result.ExpectedInMonth, result.WorkedInMonth)
+	}
+
+	day := result.Days[0]
+	if day.Kimai != 480 || day.Break != 45 || day.Diff != 0 {
+		t.Errorf("day 1: got %d in Kimai, %d break and diff %d, expected 480, 45 and 0", day.Kimai, day.Break, day.Diff)
+	}
+	if len(day.Flags) != 0 {
+		t.Errorf("day 1: got flags %v, expected none", day.Flags)
+	}
+
+	day = result.Days[1]
+	if day.Overtime != -30 || day.Diff != -450 {
+		t.Errorf("day 2: got overtime %d and diff %d, expected -30 and -450", day.Overtime, day.Diff)
+	}
+	for _, flag := range []string{FlagLargeDiff, FlagNoKimai, FlagMissingClockOut} {
+		if !day.HasFlag(flag) {
+			t.Errorf("day 2: flag %s missing in %v", flag, day.Flags)
+		}
+	}
+	if !day.Warning {
+		t.Error("day 2: no warning")
+	}
+	if len(day.Punches) != 2 {
+		t.Errorf("day 2: got %d punches, expected 2", len(day.Punches))
+	}
+
+	day = result.Days[2]
+	if day.DayType != "holiday" || day.Absence != 480 || day.Kimai != 0 {
+		t.Errorf("day 3: got %s with %d absence and %d in Kimai, expected holiday with 480 and 0", day.DayType, day.Absence, day.Kimai)
+	}
+	if !result.Days[3].HasFlag(FlagNoTimenet) {
+		t.Errorf("day 4: flag %s missing in %v", FlagNoTimenet, result.Days[3].Flags)
+	}
+
+	if result.Worked != 930 || result.Kimai != 540 || result.Diff != -390 {
+		t.Errorf("got totals %d worked, %d in Kimai and diff %d, expected 930, 540 and -390", result.Worked, result.Kimai, result.Diff)
+	}
+	if result.Overtime != -30 || result.Absence != 480 {
+		t.Errorf("got total overtime %d and absence %d, expected -30 and 480", result.Overtime, result.Absence)
+	}
+}
+
+func TestReconcileAll(t *testing.T) {
+	months := []TimenetMonth{
+		{Month: "2025-03", Days: []TimenetDay{{Date: "2025-03-03", Worked: 480}}},
+		{Month: "2025-02", Days: []TimenetDay{{Date: "2025-02-03", Worked: 480}}},
+	}
+	entries := []Entry{
+		{Date: "2025-03-03", Minutes: 480, Project: "Timo"},
+		{Date: "2025-02-03", Minutes: 420, Project: "Timo"},
+	}
+
+	results := ReconcileAll(months, entries, DefaultRules())
+	if len(results) != 2 {
+		t.Fatalf("got %d months, expected 2", len(results))
+	}
+	if results[0].Month != "2025-03" || results[0].Diff != 0 {
+		t.Errorf("first month: got %s with diff %d, expected 2025-03 with diff 0", results[0].Month, results[0].Diff)
+	}
+	if results[1].Month != "2025-02" || results[1].Diff != -60 {
+		t.Errorf("second month: got %s with diff %d, expected 2025-02 with diff -60", results[1].Month, results[1].Diff)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	rules := DefaultRules()
+	testCases := []struct {
+		name     string
+		entry    Entry
+		expected Class
+	}{
+		{"work", Entry{Project: "Timo", Activity: "Development"}, ClassWork},
+		{"break ignoring case", Entry{Project: " BREAK ", Activity: "Lunch"}, ClassBreak},
+		{"vacation", Entry{Project: "Internal", Activity: "Summer Vacation"}, ClassAbsence},
+		{"holiday", Entry{Project: "Internal", Activity: "Public holiday"}, ClassAbsence},
+		{"free time", Entry{Project: "Internal", Activity: "Free time"}, ClassAbsence},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := rules.Classify(tc.entry); got != tc.expected {
+				t.Errorf("got %s, expected %s", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+
+	rules, err := LoadRules(filepath.Join(dir, "missing.yaml"))
+	if err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+	if len(rules.Rules) != len(DefaultRules().Rules) {
+		t.Errorf("missing file: got %d rules, expected the defaults", len(rules.Rules))
+	}
+
+	path := filepath.Join(dir, "rules.yaml")
+	content := `rules:
+  - customer: acme
+    project: internal
+    class: ignore
+  - regex: "^jd"
+    field: username
+    class: break
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err = LoadRules(path)
+	if err != nil {
+		t.Fatalf("rules file: %v", err)
+	}
+	testCases := []struct {
+		name     string
+		entry    Entry
+		expected Class
+	}{
+		{"all conditions", Entry{Customer: "ACME", Project: "Internal"}, ClassIgnore},
+		{"one condition only", Entry{Customer: "ACME", Project: "Timo"}, ClassWork},
+		{"regex on field", Entry{Username: "jdoe", Activity: "Development"}, ClassBreak},
+		{"default class", Entry{Username: "asmith"}, ClassWork},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := rules.Classify(tc.entry); got != tc.expected {
+				t.Errorf("got %s, expected %s", got, tc.expected)
+			}
+		})
+	}
+
+	for name, content := range map[string]string{
+		"unknown class":   "rules:\n  - project: x\n    class: lunch\n",
+		"unknown field":   "rules:\n  - regex: x\n    field: date\n    class: work\n",
+		"invalid regex":   "rules:\n  - regex: \"(\"\n    class: work\n",
+		"unknown default": "default: billable\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadRules(path); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
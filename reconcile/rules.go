@@ -0,0 +1,158 @@
+package reconcile
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Class is how a Kimai entry counts when compared with Timenet
+type Class string
+
+const (
+	ClassWork    Class = "work"    // counts as worked time
+	ClassBreak   Class = "break"   // lunch, coffee... not worked time
+	ClassAbsence Class = "absence" // vacation, holiday, free time
+	ClassIgnore  Class = "ignore"  // not taken into account at all
+)
+
+// Rule classifies the Kimai entries it matches. Customer, project,
+// activity and username are compared ignoring case, regex is matched against
+// the field named in field (activity by default). All conditions set in a
+// rule must match.
+type Rule struct {
+	Customer string `yaml:"customer,omitempty"`
+	Project  string `yaml:"project,omitempty"`
+	Activity string `yaml:"activity,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Regex    string `yaml:"regex,omitempty"`
+	Field    string `yaml:"field,omitempty"`
+	Class    Class  `yaml:"class"`
+
+	compiled *regexp.Regexp
+}
+
+// Rules is the content of rules.yaml, the first matching rule wins
+type Rules struct {
+	Rules   []Rule `yaml:"rules"`
+	Default Class  `yaml:"default"`
+}
+
+// DefaultRules returns the behaviour timo always had: project "Break" is a break and
+// vacation, holiday and free time activities are absences
+func DefaultRules() Rules {
+	rules := Rules{
+		Rules: []Rule{
+			{Project: "break", Class: ClassBreak},
+			{Regex: "(?i)vacation|holiday|free time", Field: "activity", Class: ClassAbsence},
+		},
+		Default: ClassWork,
+	}
+	rules.compile()
+	return rules
+}
+
+// LoadRules loads the rules file, falling back to the default rules when it does not exist
+func LoadRules(path string) (Rules, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		slog.Info("No rules file found, using default rules", "path", path)
+		return DefaultRules(), nil
+	}
+	if err != nil {
+		return Rules{}, err
+	}
+
+	var rules Rules
+	if err := yaml.Unmarshal(content, &rules); err != nil {
+		return Rules{}, fmt.Errorf("invalid rules file %s: %v", path, err)
+	}
+	if rules.Default == "" {
+		rules.Default = ClassWork
+	}
+	if err := rules.compile(); err != nil {
+		return Rules{}, fmt.Errorf("invalid rules file %s: %v", path, err)
+	}
+	slog.Info("Rules file loaded", "path", path, "rules", len(rules.Rules))
+	return rules, nil
+}
+
+// validates classes and fields and compiles the regular expressions
+func (r *Rules) compile() error {
+	if !validClass(r.Default) {
+		return fmt.Errorf("unknown default class '%s'", r.Default)
+	}
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if !validClass(rule.Class) {
+			return fmt.Errorf("rule %d: unknown class '%s' (use work, break, absence or ignore)", i+1, rule.Class)
+		}
+		switch rule.Field {
+		case "":
+			rule.Field = "activity"
+		case "customer", "project", "activity", "username":
+		default:
+			return fmt.Errorf("rule %d: unknown field '%s'", i+1, rule.Field)
+		}
+		if rule.Regex != "" {
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return fmt.Errorf("rule %d: %v", i+1, err)
+			}
+			rule.compiled = re
+		}
+	}
+	return nil
+}
+
+func validClass(class Class) bool {
+	switch class {
+	case ClassWork, ClassBreak, ClassAbsence, ClassIgnore:
+		return true
+	}
+	return false
+}
+
+// Classify returns the class of a Kimai entry
+func (r Rules) Classify(entry Entry) Class {
+	for _, rule := range r.Rules {
+		if rule.matches(entry) {
+			return rule.Class
+		}
+	}
+	return r.Default
+}
+
+func (rule Rule) matches(entry Entry) bool {
+	if rule.Customer != "" && !strings.EqualFold(rule.Customer, strings.TrimSpace(entry.Customer)) {
+		return false
+	}
+	if rule.Project != "" && !strings.EqualFold(rule.Project, strings.TrimSpace(entry.Project)) {
+		return false
+	}
+	if rule.Activity != "" && !strings.EqualFold(rule.Activity, strings.TrimSpace(entry.Activity)) {
+		return false
+	}
+	if rule.Username != "" && !strings.EqualFold(rule.Username, strings.TrimSpace(entry.Username)) {
+		return false
+	}
+	if rule.compiled != nil {
+		value := entry.Activity
+		switch rule.Field {
+		case "customer":
+			value = entry.Customer
+		case "project":
+			value = entry.Project
+		case "username":
+			value = entry.Username
+		}
+		if !rule.compiled.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"timo/reconcile"
+)
+
+// rules in use, loaded at start up
+var reconcileRules = reconcile.DefaultRules()
+
+// returns the path of the rules file, ~/.config/timo/rules.yaml in Linux
+func rulesPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "timo", "rules.yaml"), nil
+}
+
+// returns a Timenet month as the reconcile package reads it
+func reconcileMonth(month TimenetMonthlyData) reconcile.TimenetMonth {
+	result := reconcile.TimenetMonth{
+		Month:    month.Month,
+		Expected: month.ExpectedMinutes,
+		Worked:   month.WorkedMinutes,
+	}
+	for _, day := range month.DailyData {
+		result.Days = append(result.Days, reconcile.TimenetDay{
+			Date:            day.Date,
+			DayType:         string(day.DayType),
+			DayLabel:        day.DayLabel,
+			Expected:        day.ExpectedMinutes,
+			Worked:          day.WorkedMinutes,
+			Overtime:        day.OvertimeMinutes,
+			Punches:         day.Punches,
+			MissingClockOut: day.missingClockOut(),
+		})
+	}
+	return result
+}
+
+// returns a Kimai entry as the reconcile package reads it
+func reconcileEntry(entry KimaiMonthlyData) reconcile.Entry {
+	return reconcile.Entry{
+		Date:     entry.Date,
+		Minutes:  entry.WorkedMinutes,
+		Customer: entry.Customer,
+		Project:  entry.Project,
+		Activity: entry.Activity,
+		Username: entry.Username,
+	}
+}
+
+func reconcileEntries(entries []KimaiMonthlyData) []reconcile.Entry {
+	result := make([]reconcile.Entry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, reconcileEntry(entry))
+	}
+	return result
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/chromedp/chromedp"
@@ -43,14 +44,10 @@ func calculateMonthsDifference(currentDate, targetDate string) int {
 	return monthsDiff
 }
 
-// set the date in the Kimai date picker for both from and to date
-func setDatePickerFilter(dateTarget string, fieldSelector string) chromedp.Action {
+// set the date in the Kimai date picker for both from and to date. The
+// field shows the date, the hidden input holds its datepicker.
+func setDatePickerFilter(dateTarget string, fieldSelector string, inputSelector string) chromedp.Action {
 	return chromedp.ActionFunc(func(ctx context.Context) error {
-		// Validate fieldSelector
-		if fieldSelector != "#ts_in" && fieldSelector != "#ts_out" {
-			return fmt.Errorf("invalid fieldSelector '%s': must be '#ts_in' or '#ts_out'", fieldSelector)
-		}
-
 		// First, read the current date from the specified field
 		var currentDateText string
 		chromedp.Text(fieldSelector, &currentDateText, chromedp.ByQuery).Do(ctx)
@@ -71,15 +68,7 @@ func setDatePickerFilter(dateTarget string, fieldSelector string) chromedp.Actio
 		chromedp.Sleep(2 * time.Second).Do(ctx)
 		chromedp.WaitVisible(fieldSelector, chromedp.ByQuery).Do(ctx)
 		slog.Info("Kimai: Triggering datepicker via JS on hidden input")
-		var inputSelector string
-		if fieldSelector == "#ts_in" {
-			inputSelector = "#pick_in"
-		} else if fieldSelector == "#ts_out" {
-			inputSelector = "#pick_out"
-		} else {
-			return fmt.Errorf("invalid fieldSelector '%s': must be '#ts_in' or '#ts_out'", fieldSelector)
-		}
-		errEval := chromedp.EvaluateAsDevTools(fmt.Sprintf("$('#%s').datepicker('show')", inputSelector[1:]), nil).Do(ctx)
+		errEval := chromedp.EvaluateAsDevTools(fmt.Sprintf("$(%q).datepicker('show')", inputSelector), nil).Do(ctx)
 		if errEval != nil {
 			slog.Error("failed to trigger datepicker via JS", "error", errEval)
 		}
@@ -125,7 +114,7 @@ func setDatePickerFilter(dateTarget string, fieldSelector string) chromedp.Actio
 		chromedp.Click(daySelector, chromedp.BySearch).Do(ctx)
 		chromedp.Sleep(800 * time.Millisecond).Do(ctx)
 
-		// Read back the field value to confirm it changed to target date (critical for #ts_out)
+		// Read back the field value to confirm it changed to target date (critical for the end date)
 		var appliedDate string
 		chromedp.Text(fieldSelector, &appliedDate, chromedp.ByQuery).Do(ctx)
 		if appliedDate == dateTarget {
@@ -160,8 +149,9 @@ func setupScraper() {
 	}
 }
 
-// creates a chromedp context with common options and timeout
-func newChromeContext(extraOpts ...chromedp.ExecAllocatorOption) (context.Context, context.CancelFunc) {
+// creates a chromedp context with common options and timeout. Cancelling
+// parent stops the scrape, the returned cancel then waits for Chromium to exit.
+func newChromeContext(parent context.Context, timeout time.Duration, extraOpts ...chromedp.ExecAllocatorOption) (context.Context, context.CancelFunc) {
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
 		chromedp.ExecPath(chromiumPath),
 		chromedp.Flag("headless", true),
@@ -191,11 +181,11 @@ func newChromeContext(extraOpts ...chromedp.ExecAllocatorOption) (context.Contex
 	)
 	opts = append(opts, extraOpts...)
 	slog.Info("Using Chrome/Chromium executable:", "path", chromiumPath)
-	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	allocCtx, allocCancel := chromedp.NewExecAllocator(parent, opts...)
 	ctx, ctxCancel := chromedp.NewContext(allocCtx)
 
 	// Set timeout
-	ctx, timeoutCancel := context.WithTimeout(ctx, 35*time.Second)
+	ctx, timeoutCancel := context.WithTimeout(ctx, timeout)
 
 	// Compose all cancels into one
 	cancel := func() {
@@ -206,11 +196,91 @@ func newChromeContext(extraOpts ...chromedp.ExecAllocatorOption) (context.Contex
 	return ctx, cancel
 }
 
+// scraping steps are tried stepAttempts times, each limited to stepTimeout,
+// waiting stepBackoff after the first failure and twice as long each time
+var (
+	stepAttempts = 3
+	stepTimeout  = 10 * time.Second
+	stepBackoff  = 1 * time.Second
+)
+
+// runs a scraping step again when it fails, so a slow page or a lost click
+// does not abort the whole fetch
+func retryStep(name string, action chromedp.Action) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		backoff := stepBackoff
+		for attempt := 1; ; attempt++ {
+			stepCtx, cancel := context.WithTimeout(ctx, stepTimeout)
+			err := action.Do(stepCtx)
+			cancel()
+			if err == nil {
+				return nil
+			}
+			if attempt == stepAttempts || ctx.Err() != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			slog.Warn("Retrying scraping step", "step", name, "attempt", attempt, "wait", backoff, "error", err)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return fmt.Errorf("%s: %v", name, ctx.Err())
+			}
+			backoff *= 2
+		}
+	})
+}
+
+// returns the month shown in the month header of the checks page
+func shownMonth(ctx context.Context, sel TimenetSelectors) (time.Time, error) {
+	var header string
+	if err := chromedp.Text(sel.MonthHeader+" h2", &header, chromedp.ByQuery).Do(ctx); err != nil {
+		return time.Time{}, err
+	}
+	return parseMonthHeader(strings.TrimSpace(header))
+}
+
+// returns the first day of the month n months before t
+func monthsBefore(t time.Time, n int) time.Time {
+	return time.Date(t.Year(), t.Month()-time.Month(n), 1, 0, 0, 0, 0, time.Local)
+}
+
+// goes back from the month after want to want, the first day of a month. Each attempt reads the month
+// header first and clicks only while it still shows the month after, so a
+// click that lands late is never repeated and no month is skipped.
+func previousMonth(sel TimenetSelectors, want time.Time) chromedp.Action {
+	return retryStep("go to "+want.Format("2006-01"), chromedp.ActionFunc(func(ctx context.Context) error {
+		shown, err := shownMonth(ctx, sel)
+		if err != nil {
+			return err
+		}
+		if shown.Equal(want) {
+			return nil
+		}
+		if !shown.Equal(monthsBefore(want, -1)) {
+			return fmt.Errorf("month header shows %s", shown.Format("2006-01"))
+		}
+		if err := chromedp.Click(sel.PreviousMonth, chromedp.ByQuery).Do(ctx); err != nil {
+			return err
+		}
+		for !shown.Equal(want) {
+			select {
+			case <-time.After(100 * time.Millisecond):
+			case <-ctx.Done():
+				return fmt.Errorf("month header still shows %s", shown.Format("2006-01"))
+			}
+			if shown, err = shownMonth(ctx, sel); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
 // append the HTML content of the specified selector to the target string
 func appendHTML(selector string, target *string) chromedp.Action {
 	return chromedp.ActionFunc(func(ctx context.Context) error {
 
-		err := chromedp.WaitVisible(`div.card`, chromedp.ByQuery).Do(ctx)
+		err := chromedp.WaitVisible(selector, chromedp.ByQuery).Do(ctx)
 		if err != nil {
 			return err
 		}
@@ -226,49 +296,34 @@ func appendHTML(selector string, target *string) chromedp.Action {
 	})
 }
 
-// scrape timenet website content from january first of curent year
-func scrapeTimenet(password string) (string, error) {
-
-	ctx, cancel := newChromeContext(
-	//chromedp.Flag("headless", false),
-	)
-	defer cancel()
-
-	monthsToGoBack := int(time.Now().Month() - time.January)
-	slog.Info("Timenet: Scraping months from January to current month",
-		"monthsToScrape", monthsToGoBack+1, "currentMonth", time.Now().Month().String())
-
-	// scrape last 12 months
-	//monthsToGoBack := 11 // Always go back 11 months to get 12 months total (current + 11 previous)
-	//slog.Info("Timenet. Scraping 12 months of data")
-
-	var responseHTML string
-
-	err := chromedp.Run(ctx,
+// logs into Timenet and opens the checks page on the current month
+func timenetLogin(password string) chromedp.Tasks {
+	sel := config.Timenet.Selectors
+	return chromedp.Tasks{
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			slog.Info("Timenet: Navigating to Timenet login page")
-			return chromedp.Navigate("https://timenet-wcp.gpisoftware.com/login/28b27216-c0c8-469c-816b-c65d0a11c7dd").Do(ctx)
+			return chromedp.Navigate(config.Timenet.loginURL()).Do(ctx)
 		}),
-		chromedp.Sleep(1*time.Second),
+		chromedp.Sleep(1 * time.Second),
 
 		// login
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			slog.Info("Timenet: Waiting for login input to be visible")
-			return chromedp.WaitVisible(`#gpi-input-0`, chromedp.ByQuery).Do(ctx)
+			return chromedp.WaitVisible(sel.Password, chromedp.ByQuery).Do(ctx)
 		}),
-		chromedp.Clear(`#gpi-input-0`, chromedp.ByQuery),
+		chromedp.Clear(sel.Password, chromedp.ByQuery),
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			slog.Info("Timenet: Entering password and submitting")
-			return chromedp.SendKeys(`#gpi-input-0`, password+"\n", chromedp.ByQuery).Do(ctx)
+			return chromedp.SendKeys(sel.Password, password+"\n", chromedp.ByQuery).Do(ctx)
 		}),
 
-		chromedp.Sleep(1*time.Second),
+		chromedp.Sleep(1 * time.Second),
 
 		// go to checks page
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			slog.Info("Timenet: Waiting for checks navigation link to be clickable")
 			// First wait for the link to be visible
-			err := chromedp.WaitVisible(`a.nav-link[href="/checks"]`, chromedp.ByQuery).Do(ctx)
+			err := retryStep("wait for checks link", chromedp.WaitVisible(sel.ChecksLink, chromedp.ByQuery)).Do(ctx)
 			if err != nil {
 				slog.Error("Timenet: CHECKS LINK not visible", "error", err)
 				return err
@@ -276,14 +331,14 @@ func scrapeTimenet(password string) (string, error) {
 			// Add extra wait for Windows headless mode
 			chromedp.Sleep(1 * time.Second).Do(ctx)
 			slog.Info("Timenet: Clicking checks navigation link")
-			return chromedp.Click(`a.nav-link[href="/checks"]`, chromedp.ByQuery).Do(ctx)
+			return retryStep("click checks link", chromedp.Click(sel.ChecksLink, chromedp.ByQuery)).Do(ctx)
 		}),
-		chromedp.Sleep(1*time.Second), // Give more time for navigation
+		chromedp.Sleep(1 * time.Second), // Give more time for navigation
 
 		// Verify we're on the checks page by waiting for a checks-specific element
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			slog.Info("Timenet: Waiting for checks page to load")
-			err := chromedp.WaitVisible(`div.container-mes-checks`, chromedp.ByQuery).Do(ctx)
+			err := retryStep("wait for checks page", chromedp.WaitVisible(sel.MonthHeader, chromedp.ByQuery)).Do(ctx)
 			if err != nil {
 				slog.Error("Timenet: Checks page container not found", "error", err)
 				return err
@@ -291,26 +346,73 @@ func scrapeTimenet(password string) (string, error) {
 			slog.Info("Timenet: Successfully navigated to checks page")
 			return nil
 		}),
+	}
+}
+
+// scrape timenet website content for all months in the fetch range.
+// Timenet opens on the current month, so months after the range are skipped
+// and then every month is captured going back until the first one of the range.
+func scrapeTimenet(parent context.Context, password string, r FetchRange) (string, error) {
+
+	sel := config.Timenet.Selectors
+	ctx, cancel := newChromeContext(parent, config.Timenet.Timeout,
+		chromedp.Flag("headless", true),
+		//chromedp.Flag("headless", false),
+	)
+	defer cancel()
+
+	monthsToSkip := monthsBetween(r.To, time.Now())
+	monthsToScrape := monthsBetween(r.From, r.To) + 1
+	slog.Info("Timenet: Scraping months in fetch range",
+		"from", r.From.Format("2006-01"), "to", r.To.Format("2006-01"),
+		"monthsToSkip", monthsToSkip, "monthsToScrape", monthsToScrape)
+
+	var responseHTML string
+	scraped := 0 // months appended to responseHTML
+
+	session := newBrowserSession("Timenet", password)
+	err := chromedp.Run(ctx,
+		loginWithSession(session, config.Timenet.checksURL(), sel.MonthHeader, sel.Password, timenetLogin(password)),
+
+		// skip the months after the end of the fetch range
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			for i := 0; i < monthsToSkip; i++ {
+				err := retryStep("wait for month card", chromedp.WaitVisible(sel.MonthCard, chromedp.ByQuery)).Do(ctx)
+				if err != nil {
+					return err
+				}
+				err = previousMonth(sel, monthsBefore(time.Now(), i+1)).Do(ctx)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
 
-		// loop monthsToGoBack times to go back to January of the same year
+		// loop monthsToScrape times to go back to the first month of the fetch range
 		chromedp.ActionFunc(func(ctx context.Context) error {
-			slog.Info("Timenet: Starting month iteration loop", "totalMonths", monthsToGoBack+1)
-			for i := 0; i < monthsToGoBack+1; i++ {
-				slog.Info("Timenet: Processing month iteration", "iteration", i+1, "of", monthsToGoBack+1)
+			slog.Info("Timenet: Starting month iteration loop", "totalMonths", monthsToScrape)
+			for i := 0; i < monthsToScrape; i++ {
+				slog.Info("Timenet: Processing month iteration", "iteration", i+1, "of", monthsToScrape)
 
 				var err error
 
 				// append current month HTML to responseHTML
-				err = appendHTML("div.card", &responseHTML).Do(ctx)
+				err = retryStep("read month card", appendHTML(sel.MonthCard, &responseHTML)).Do(ctx)
 				if err != nil {
 					slog.Error("Timenet: Failed to append HTML", "iteration", i+1, "error", err)
 					return err
 				}
+				scraped++
+				reportProgress(ctx, fetchProgress{Stage: stageMonth, Month: scraped})
+				if scraped == monthsToScrape {
+					break
+				}
 
 				chromedp.Sleep(50 * time.Millisecond).Do(ctx)
 
 				// click back button to go to previous month
-				err = chromedp.Click(`div.container-mes-checks button:first-child`, chromedp.ByQuery).Do(ctx)
+				err = previousMonth(sel, monthsBefore(r.To, scraped)).Do(ctx)
 				if err != nil {
 					return err
 				}
@@ -320,7 +422,22 @@ func scrapeTimenet(password string) (string, error) {
 		}),
 	)
 
+	// a cancelled fetch says nothing about the stored session, it is kept
+	if err != nil && parent.Err() != nil {
+		return "", parent.Err()
+	}
+
+	// months already read are kept, from the last one of the range backwards
+	if err != nil && scraped > 0 {
+		slog.Warn("Timenet: Scrape stopped, keeping the months read", "scraped", scraped, "of", monthsToScrape, "error", err)
+		return responseHTML, &partialFetchError{
+			Fetched: FetchRange{From: r.To.AddDate(0, 1-scraped, 0), To: r.To},
+			Wanted:  monthsToScrape,
+			Err:     err,
+		}
+	}
 	if err != nil {
+		session.forget()
 		return "", fmt.Errorf("failed to scrape Timenet Web: %v", err)
 	}
 
@@ -338,13 +455,32 @@ func scrapeTimenet(password string) (string, error) {
 
 }
 
-// scrape kimai website content from january first of curent year.
+// logs into Kimai 1
+func kimaiLogin(id string, password string) chromedp.Tasks {
+	sel := config.Kimai.Selectors
+	return chromedp.Tasks{
+		chromedp.Navigate(config.Kimai.URL),
+		chromedp.Sleep(1 * time.Second),
+		chromedp.WaitVisible(sel.Username, chromedp.ByQuery),
+		chromedp.Clear(sel.Username, chromedp.ByQuery),
+		chromedp.SendKeys(sel.Username, id, chromedp.ByQuery),
+		chromedp.Sleep(300 * time.Millisecond),
+		chromedp.Clear(sel.Password, chromedp.ByQuery),
+		chromedp.SendKeys(sel.Password, password, chromedp.ByQuery),
+		chromedp.Sleep(300 * time.Millisecond),
+		chromedp.Click(sel.LoginButton, chromedp.ByQuery),
+		chromedp.Sleep(1 * time.Second),
+	}
+}
+
+// scrape kimai website content for the fetch range.
 // Once logged into the kimai site store current view filter then
-// sets it to January 1st of current year and once finished scraping
+// sets it to the first day of the range and once finished scraping
 // re-sets to its original state.
-func scrapeKimai(id string, password string) (string, error) {
+func scrapeKimai(parent context.Context, id string, password string, r FetchRange) (string, error) {
 
-	ctx, cancel := newChromeContext(
+	sel := config.Kimai.Selectors
+	ctx, cancel := newChromeContext(parent, config.Kimai.Timeout,
 		chromedp.Flag("ignore-certificate-errors", true),
 		//chromedp.Flag("headless", false),
 	)
@@ -356,35 +492,20 @@ func scrapeKimai(id string, password string) (string, error) {
 	var viewFilterStartDate string
 	var viewFilterEndDate string
 
-	var currentDate string = time.Now().Format("02/01/2006")
-
-	// Calculate January 1st of the year in currentDate
-	var day, month, year int
-	fmt.Sscanf(currentDate, "%d/%d/%d", &day, &month, &year)
-	januaryFirst := fmt.Sprintf("01/01/%d", year)
+	// first day of the first month and last day of the last month in the range
+	firstDayOfRange := time.Date(r.From.Year(), r.From.Month(), 1, 0, 0, 0, 0, time.Local)
+	lastDayOfRange := time.Date(r.To.Year(), r.To.Month()+1, 1, 0, 0, 0, 0, time.Local).AddDate(0, 0, -1)
+	firstDayOfRangeStr := firstDayOfRange.Format("02/01/2006")
+	lastDayOfRangeStr := lastDayOfRange.Format("02/01/2006")
 
-	// Calculate last day of the month and year in currentDate
-	firstOfNextMonth := time.Date(year, time.Month(month+1), 1, 0, 0, 0, 0, time.UTC)
-	lastDayOfMonth := firstOfNextMonth.AddDate(0, 0, -1)
-	lastDayOfMonthStr := lastDayOfMonth.Format("02/01/2006")
-
-	slog.Info("Kimai URL is going to be scraped", "fromDate", januaryFirst, "toDate", lastDayOfMonthStr)
+	slog.Info("Kimai URL is going to be scraped", "fromDate", firstDayOfRangeStr, "toDate", lastDayOfRangeStr)
 
+	session := newBrowserSession("Kimai", id+"\n"+password)
 	err := chromedp.Run(ctx,
-		// login
-		chromedp.Navigate("https://kimai.itk-spain.com/index.php"),
-		chromedp.Sleep(1*time.Second),
-		chromedp.WaitVisible(`#kimaiusername`, chromedp.ByQuery),
-		chromedp.Clear(`#kimaiusername`, chromedp.ByQuery),
-		chromedp.SendKeys(`#kimaiusername`, id, chromedp.ByQuery),
-		chromedp.Sleep(300*time.Millisecond),
-		chromedp.Clear(`#kimaipassword`, chromedp.ByQuery),
-		chromedp.SendKeys(`#kimaipassword`, password, chromedp.ByQuery),
-		chromedp.Sleep(300*time.Millisecond),
-		chromedp.Click(`#loginButton`, chromedp.ByQuery),
-		chromedp.Sleep(1*time.Second),
+		// login, skipped while the stored session is still valid
+		loginWithSession(session, config.Kimai.homeURL(), sel.Dates, sel.Username, kimaiLogin(id, password)),
 
-		// in Kimai preference should be set to 920 entries per page
+		// in Kimai preference should be set to row_limit (920) entries per page
 		// Wait for floaterShow function to be available
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			for i := 0; i < 10; i++ { // Try up to 5s
@@ -400,55 +521,61 @@ func scrapeKimai(id string, password string) (string, error) {
 		chromedp.Evaluate(`floaterShow("floaters.php","prefs",0,0,450);`, nil), // open preferences floating panel
 
 		chromedp.Sleep(1*time.Second),
-		chromedp.WaitVisible(`#floater`, chromedp.ByQuery),
+		chromedp.WaitVisible(sel.Floater, chromedp.ByQuery),
 		chromedp.Sleep(1*time.Second),
-		chromedp.Click(`#floater .menu.tabSelection li:nth-child(3)`, chromedp.ByQuery),
+		chromedp.Click(sel.PrefsTab, chromedp.ByQuery),
 		chromedp.Sleep(300*time.Millisecond),
-		chromedp.WaitVisible(`#rowlimit`, chromedp.ByQuery),
+		chromedp.WaitVisible(sel.RowLimit, chromedp.ByQuery),
 		chromedp.Sleep(300*time.Millisecond),
-		chromedp.Clear(`#rowlimit`, chromedp.ByQuery),
-		chromedp.SendKeys(`#rowlimit`, "920\n", chromedp.ByQuery),
+		chromedp.Clear(sel.RowLimit, chromedp.ByQuery),
+		chromedp.SendKeys(sel.RowLimit, fmt.Sprintf("%d\n", config.Kimai.RowLimit), chromedp.ByQuery),
 		chromedp.Sleep(300*time.Millisecond),
 
 		// wait for date picker elements to be visible/loaded
-		chromedp.WaitVisible(`#dates`, chromedp.ByQuery),
+		chromedp.WaitVisible(sel.Dates, chromedp.ByQuery),
 		chromedp.Sleep(300*time.Millisecond),
-		chromedp.WaitVisible(`#ts_in`, chromedp.ByQuery),
-		chromedp.WaitVisible(`#ts_out`, chromedp.ByQuery),
+		chromedp.WaitVisible(sel.FromField, chromedp.ByQuery),
+		chromedp.WaitVisible(sel.ToField, chromedp.ByQuery),
 
 		// store locally the current view filter
-		chromedp.Text(`#ts_in`, &viewFilterOriginalStartDate, chromedp.ByQuery),
-		chromedp.Text(`#ts_out`, &viewFilterOriginalEndDate, chromedp.ByQuery),
+		chromedp.Text(sel.FromField, &viewFilterOriginalStartDate, chromedp.ByQuery),
+		chromedp.Text(sel.ToField, &viewFilterOriginalEndDate, chromedp.ByQuery),
 
-		// set the from view filter to January 1st of current year
-		setDatePickerFilter(januaryFirst, "#ts_in"),
+		// set the from view filter to the first day of the range
+		setDatePickerFilter(firstDayOfRangeStr, sel.FromField, sel.FromInput),
 
-		// set the to view filter to last day of current month
-		setDatePickerFilter(lastDayOfMonthStr, "#ts_out"),
+		// set the to view filter to the last day of the range
+		setDatePickerFilter(lastDayOfRangeStr, sel.ToField, sel.ToInput),
 
 		// wait for date picker elements to be visible/loaded
-		chromedp.WaitVisible(`#dates`, chromedp.ByQuery),
+		chromedp.WaitVisible(sel.Dates, chromedp.ByQuery),
 		chromedp.Sleep(1*time.Second),
 
 		// store locally current view filter
-		chromedp.Text(`#ts_in`, &viewFilterStartDate, chromedp.ByQuery),
-		chromedp.Text(`#ts_out`, &viewFilterEndDate, chromedp.ByQuery),
+		chromedp.Text(sel.FromField, &viewFilterStartDate, chromedp.ByQuery),
+		chromedp.Text(sel.ToField, &viewFilterEndDate, chromedp.ByQuery),
 
-		// scrape current year data content
+		// scrape fetch range data content
 		chromedp.OuterHTML(`html`, &responseHTML, chromedp.ByQuery),
 	)
 	slog.Info("Just scraped Kimai content with View filter", "start", viewFilterStartDate, "end", viewFilterEndDate)
 
+	// a cancelled fetch says nothing about the stored session, it is kept
+	if err != nil && parent.Err() != nil {
+		return "", parent.Err()
+	}
 	if err != nil {
+		session.forget()
 		return "", fmt.Errorf("failed to scrape Kimai: %v", err)
 	}
+	reportProgress(ctx, fetchProgress{Stage: stageMonth, Month: r.months()})
 
 	// restore original date picker view filter
 	err1 := chromedp.Run(ctx,
 		chromedp.Sleep(1*time.Second),
-		setDatePickerFilter(viewFilterOriginalStartDate, "#ts_in"),
+		setDatePickerFilter(viewFilterOriginalStartDate, sel.FromField, sel.FromInput),
 		chromedp.Sleep(1*time.Second),
-		setDatePickerFilter(viewFilterOriginalEndDate, "#ts_out"),
+		setDatePickerFilter(viewFilterOriginalEndDate, sel.ToField, sel.ToInput),
 	)
 	slog.Info("Kimai: Restored original view filter", "start", viewFilterOriginalStartDate, "end", viewFilterOriginalEndDate)
 
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// TestScrapers runs scrapeTimenet and scrapeKimai against the fake sites,
+// it needs Chromium but no network
+func TestScrapers(t *testing.T) {
+	if chromiumPath == "" {
+		chromiumPath = FindChromiumExecutable()
+	}
+	if chromiumPath == "" {
+		// CI sets it so a runner without a browser fails instead of passing
+		if os.Getenv("TIMO_REQUIRE_CHROMIUM") != "" {
+			t.Fatal("no Chrome/Chromium found and TIMO_REQUIRE_CHROMIUM is set")
+		}
+		t.Skip("no Chrome/Chromium found")
+	}
+
+	timenet := newFakeTimenet("00000000-0000-0000-0000-000000000000", "timenet-secret")
+	defer timenet.Close()
+	kimai := newFakeKimai("jdoe", "kimai-secret")
+	defer kimai.Close()
+
+	saved := config
+	t.Cleanup(func() { config = saved })
+	// sessions stored by the first fetches are reused by the next ones
+	dir := t.TempDir()
+	sessionDir = dir
+	t.Cleanup(func() { sessionDir = "" })
+	config.KeepSessions = true
+	config.Timenet.URL = timenet.URL
+	config.Timenet.TenantID = "00000000-0000-0000-0000-000000000000"
+	config.Timenet.Timeout = 2 * time.Minute
+	config.Kimai.URL = kimai.URL + "/index.php"
+	config.Kimai.Timeout = 2 * time.Minute
+
+	// the month before the current one and the current one
+	r := FetchRange{From: fakeMonth(1), To: fakeMonth(0)}
+	entriesInRange := 0
+	for _, entry := range fakeKimaiEntries("jdoe") {
+		if !parseDDMMYYYY(entry.Date).Before(r.From) {
+			entriesInRange++
+		}
+	}
+
+	t.Run("Timenet", func(t *testing.T) {
+		var steps []string
+		progressCtx := withProgress(context.Background(), func(p fetchProgress) {
+			steps = append(steps, p.String())
+		})
+		html, err := scrapeTimenet(withProgressSource(progressCtx, "Timenet", r.months()), "timenet-secret", r)
+		if err != nil {
+			t.Fatalf("scrape: %v", err)
+		}
+		if got := strings.Join(steps, ", "); got != "logged in, month 1/2 captured, month 2/2 captured" {
+			t.Errorf("got progress '%s', expected 'logged in, month 1/2 captured, month 2/2 captured'", got)
+		}
+		cleanHTML(&html)
+		data, err := timenetParse(&html)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if issues := checkTimenetData("Timenet", data, r).summary(); issues != "" {
+			t.Errorf("got diagnostics '%s'", issues)
+		}
+		if len(data.MonthlyData) != 2 {
+			t.Fatalf("got %d months, expected 2", len(data.MonthlyData))
+		}
+		if month := data.MonthlyData[0].Month; month != r.To.Format("2006-01") {
+			t.Errorf("got first month %s, expected %s", month, r.To.Format("2006-01"))
+		}
+		previous := data.MonthlyData[1]
+		if previous.Month != r.From.Format("2006-01") {
+			t.Errorf("got second month %s, expected %s", previous.Month, r.From.Format("2006-01"))
+		}
+		if days := len(previous.DailyData); days != r.To.AddDate(0, 0, -1).Day() {
+			t.Errorf("got %d days, expected %d", days, r.To.AddDate(0, 0, -1).Day())
+		}
+		worked := 8 * 60 * countWeekDays(r.From)
+		if previous.WorkedMinutes != worked {
+			t.Errorf("got %d minutes worked, expected %d", previous.WorkedMinutes, worked)
+		}
+		punched := 0
+		for _, day := range previous.DailyData {
+			punched += punchedMinutes(day.Punches)
+		}
+		if punched != worked {
+			t.Errorf("got %d minutes punched, expected %d", punched, worked)
+		}
+	})
+
+	// the same months read from the backend JSON
+	t.Run("Timenet network", func(t *testing.T) {
+		config.Timenet.Mode = "network"
+		config.Timenet.Network = fakeTimenetNetwork
+		defer func() {
+			config.Timenet.Mode = "dom"
+			config.Timenet.Network = TimenetNetwork{}
+		}()
+		raw, err := captureTimenet(context.Background(), "timenet-secret", r)
+		if err != nil {
+			t.Fatalf("capture: %v", err)
+		}
+		data, err := timenetNetworkParse(&raw)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if issues := checkTimenetData("Timenet", data, r).summary(); issues != "" {
+			t.Errorf("got diagnostics '%s'", issues)
+		}
+		if len(data.MonthlyData) != 2 {
+			t.Fatalf("got %d months, expected 2", len(data.MonthlyData))
+		}
+		if month := data.MonthlyData[0].Month; month != r.To.Format("2006-01") {
+			t.Errorf("got first month %s, expected %s", month, r.To.Format("2006-01"))
+		}
+		if worked := data.MonthlyData[1].WorkedMinutes; worked != 8*60*countWeekDays(r.From) {
+			t.Errorf("got %d minutes worked, expected %d", worked, 8*60*countWeekDays(r.From))
+		}
+		if data.OvertimeMinutesInYear != -60*60 {
+			t.Errorf("got %d minutes of yearly overtime, expected %d", data.OvertimeMinutesInYear, -60*60)
+		}
+	})
+
+	t.Run("Kimai", func(t *testing.T) {
+		html, err := scrapeKimai(context.Background(), "jdoe", "kimai-secret", r)
+		if err != nil {
+			t.Fatalf("scrape: %v", err)
+		}
+		data, err := kimaiParse(&html)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if data.Summary.LoggedinUser != "jdoe" {
+			t.Errorf("got user '%s', expected 'jdoe'", data.Summary.LoggedinUser)
+		}
+		if issues := checkKimaiData("Kimai", data, r).summary(); issues != "" {
+			t.Errorf("got diagnostics '%s'", issues)
+		}
+		if len(data.MonthlyData) != entriesInRange {
+			t.Fatalf("got %d entries, expected %d", len(data.MonthlyData), entriesInRange)
+		}
+		if worked := data.MonthlyData[0].WorkedMinutes; worked != 240 {
+			t.Errorf("got %d minutes worked in the first entry, expected 240", worked)
+		}
+		billable, ids := 0, make(map[int]bool)
+		for _, entry := range data.MonthlyData {
+			if key := kimaiMonthKey(entry); key < r.From.Format("2006-01") || key > r.To.Format("2006-01") {
+				t.Errorf("got entry of %s, outside the fetch range", entry.Date)
+			}
+			if entry.ID != 0 {
+				ids[entry.ID] = true
+			}
+			if entry.Billable && entry.Amount == 200 {
+				billable++
+			}
+		}
+		if len(ids) != entriesInRange {
+			t.Errorf("got %d distinct entry IDs, expected %d", len(ids), entriesInRange)
+		}
+		if billable != entriesInRange/2 {
+			t.Errorf("got %d billable entries, expected %d", billable, entriesInRange/2)
+		}
+	})
+
+	// both sessions were stored, a valid one skips the login and an expired
+	// one is detected on the login page
+	t.Run("Sessions", func(t *testing.T) {
+		for _, name := range []string{"timenet", "kimai"} {
+			if _, err := os.Stat(filepath.Join(dir, name+".json")); err != nil {
+				t.Errorf("%s session not stored: %v", name, err)
+			}
+		}
+		stored, err := readSession(filepath.Join(dir, "kimai.json"), "jdoe\nkimai-secret")
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		for i := range stored.Cookies {
+			stored.Cookies[i].Value = "expired"
+		}
+		writeSession(filepath.Join(dir, "expired.json"), "jdoe\nkimai-secret", stored)
+		var restored, expired bool
+		ctx, cancel := newChromeContext(context.Background(), time.Minute)
+		err = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			sel := config.Kimai.Selectors
+			var err error
+			if expired, err = newBrowserSession("Expired", "jdoe\nkimai-secret").restore(ctx, config.Kimai.homeURL(), sel.Dates, sel.Username); err != nil {
+				return err
+			}
+			restored, err = newBrowserSession("Kimai", "jdoe\nkimai-secret").restore(ctx, config.Kimai.homeURL(), sel.Dates, sel.Username)
+			return err
+		}))
+		cancel()
+		if err != nil {
+			t.Fatalf("probe: %v", err)
+		}
+		if expired {
+			t.Error("expired session restored")
+		}
+		if !restored {
+			t.Error("valid session not restored")
+		}
+	})
+
+	// the previous month button breaks after the first month, the month read
+	// is kept and reported as a partial fetch
+	t.Run("Timenet partial", func(t *testing.T) {
+		savedTimeout, savedBackoff := stepTimeout, stepBackoff
+		stepTimeout, stepBackoff = 2*time.Second, 100*time.Millisecond
+		config.Timenet.Selectors.PreviousMonth = "button.missing"
+		defer func() {
+			config.Timenet.Selectors.PreviousMonth = saved.Timenet.Selectors.PreviousMonth
+			stepTimeout, stepBackoff = savedTimeout, savedBackoff
+		}()
+		html, err := (&timenetSource{password: "timenet-secret"}).Fetch(context.Background(), r)
+		var partial *partialFetchError
+		if !errors.As(err, &partial) {
+			t.Fatalf("got %v, expected a partial fetch", err)
+		}
+		if !strings.HasPrefix(partial.Error(), "1/2 months fetched") {
+			t.Errorf("got '%s', expected '1/2 months fetched'", partial.Error())
+		}
+		if !partial.Fetched.From.Equal(r.To) || !partial.Fetched.To.Equal(r.To) {
+			t.Errorf("got range %v, expected only %s", partial.Fetched, r.To.Format("2006-01"))
+		}
+		data, err := timenetParse(&html)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if len(data.MonthlyData) != 1 {
+			t.Errorf("got %d months, expected 1", len(data.MonthlyData))
+		}
+	})
+
+	// a month shown only after the step timed out is not clicked past
+	t.Run("Timenet slow month change", func(t *testing.T) {
+		savedTimeout, savedBackoff := stepTimeout, stepBackoff
+		stepTimeout, stepBackoff = time.Second, 500*time.Millisecond
+		fakeTimenetClickDelay = 1200 * time.Millisecond
+		defer func() {
+			stepTimeout, stepBackoff = savedTimeout, savedBackoff
+			fakeTimenetClickDelay = 0
+		}()
+		html, err := scrapeTimenet(context.Background(), "timenet-secret", r)
+		if err != nil {
+			t.Fatalf("scrape: %v", err)
+		}
+		data, err := timenetParse(&html)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if len(data.MonthlyData) != 2 {
+			t.Fatalf("got %d months, expected 2", len(data.MonthlyData))
+		}
+		if month := data.MonthlyData[1].Month; month != r.From.Format("2006-01") {
+			t.Errorf("got second month %s, expected %s", month, r.From.Format("2006-01"))
+		}
+	})
+
+	// a fetch cancelled after the login stops at once, closes Chromium and
+	// keeps the session
+	t.Run("Timenet cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ctx = withProgress(ctx, func(p fetchProgress) {
+			if p.Stage == stageLoggedIn {
+				cancel()
+			}
+		})
+		start := time.Now()
+		_, err := scrapeTimenet(ctx, "timenet-secret", FetchRange{From: fakeMonth(fakeMonths - 1), To: fakeMonth(0)})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, expected %v", err, context.Canceled)
+		}
+		if elapsed := time.Since(start); elapsed >= 10*time.Second {
+			t.Errorf("cancelled fetch took %v", elapsed)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "timenet.json")); err != nil {
+			t.Errorf("session not kept after cancel: %v", err)
+		}
+	})
+
+	// a wrong password never gets past the login page
+	t.Run("Timenet wrong password", func(t *testing.T) {
+		config.Timenet.Timeout = 15 * time.Second
+		if _, err := scrapeTimenet(context.Background(), "wrong", r); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+// TestRetryStep retries failing steps with short timeouts and checks the
+// partial fetch error reported with the months read
+func TestRetryStep(t *testing.T) {
+	savedTimeout, savedBackoff := stepTimeout, stepBackoff
+	stepTimeout, stepBackoff = 200*time.Millisecond, 10*time.Millisecond
+	defer func() { stepTimeout, stepBackoff = savedTimeout, savedBackoff }()
+
+	// fails until the given attempt
+	attempts := 0
+	failUntil := func(success int) chromedp.Action {
+		attempts = 0
+		return chromedp.ActionFunc(func(ctx context.Context) error {
+			attempts++
+			if attempts < success {
+				return fmt.Errorf("node not found")
+			}
+			return nil
+		})
+	}
+
+	if err := retryStep("click", failUntil(stepAttempts)).Do(context.Background()); err != nil {
+		t.Errorf("step succeeding on the last attempt failed: %v", err)
+	}
+	if attempts != stepAttempts {
+		t.Errorf("got %d attempts, expected %d", attempts, stepAttempts)
+	}
+
+	failedErr := retryStep("click", failUntil(stepAttempts+1)).Do(context.Background())
+	if failedErr == nil || failedErr.Error() != "click: node not found" {
+		t.Errorf("got %v, expected click: node not found", failedErr)
+	}
+	if attempts != stepAttempts {
+		t.Errorf("got %d attempts before failing, expected %d", attempts, stepAttempts)
+	}
+
+	// a step hanging is stopped by its own timeout and tried again
+	start := time.Now()
+	err := retryStep("wait", chromedp.ActionFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})).Do(context.Background())
+	if err == nil || err.Error() != "wait: context deadline exceeded" {
+		t.Errorf("got %v for a hanging step, expected wait: context deadline exceeded", err)
+	}
+	if hung, limit := time.Since(start), time.Duration(stepAttempts)*stepTimeout+time.Second; hung > limit {
+		t.Errorf("hanging step took %v, expected less than %v", hung, limit)
+	}
+
+	// a cancelled fetch is not tried again
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := retryStep("click", failUntil(stepAttempts)).Do(ctx); err == nil {
+		t.Errorf("cancelled step did not fail")
+	}
+	if attempts != 1 {
+		t.Errorf("got %d attempts of a cancelled step, expected 1", attempts)
+	}
+
+	to := time.Date(2025, time.September, 1, 0, 0, 0, 0, time.Local)
+	partial := &partialFetchError{Fetched: FetchRange{From: to.AddDate(0, -6, 0), To: to}, Wanted: 9, Err: failedErr}
+	if got := partial.Error(); got != "7/9 months fetched (click: node not found)" {
+		t.Errorf("got '%s', expected '7/9 months fetched (click: node not found)'", got)
+	}
+	var unwrapped *partialFetchError
+	if !errors.As(fmt.Errorf("wrapped: %w", partial), &unwrapped) {
+		t.Fatalf("partial fetch error not found when wrapped")
+	}
+	if months := unwrapped.Fetched.months(); months != 7 {
+		t.Errorf("got %d months fetched, expected 7", months)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/chromedp"
+)
+
+// BROWSER SESSIONS
+// The cookies of each site are kept after a login, so the next fetch skips
+// it while the site still accepts them. They are encrypted like the vault,
+// with a key derived from the site password instead of a passphrase: only
+// who can log in anyway can read them, and a new password drops them.
+
+// folder of the session files, ~/.config/timo/sessions in Linux when blank
+var sessionDir string = ""
+
+// how long to wait for a restored session to show either page
+const sessionProbeTimeout = 10 * time.Second
+
+// one browser cookie as stored in a session file
+type sessionCookie struct {
+	Name     string  `json:"name"`
+	Value    string  `json:"value"`
+	Domain   string  `json:"domain"`
+	Path     string  `json:"path"`
+	Expires  float64 `json:"expires"` // seconds since epoch, 0 for session cookies
+	Secure   bool    `json:"secure"`
+	HTTPOnly bool    `json:"http_only"`
+	SameSite string  `json:"same_site"`
+}
+
+// content of a session file once decrypted
+type sessionContent struct {
+	SavedAt time.Time       `json:"saved_at"`
+	Cookies []sessionCookie `json:"cookies"`
+}
+
+// browserSession keeps the cookies of one site between fetches, nil when
+// sessions are turned off. Methods of a nil session do nothing.
+type browserSession struct {
+	name     string // name of the source, e.g. Timenet
+	path     string
+	secret   string // site password, with the user name when there is one
+	restored bool
+}
+
+// returns the session of a source, nil when sessions are turned off
+func newBrowserSession(name string, secret string) *browserSession {
+	if !config.KeepSessions || secret == "" {
+		return nil
+	}
+	dir := sessionDir
+	if dir == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			slog.Warn("No config folder, sessions not kept", "error", err)
+			return nil
+		}
+		dir = filepath.Join(configDir, "timo", "sessions")
+	}
+	return &browserSession{name: name, path: filepath.Join(dir, sourceKey(name)+".json"), secret: secret}
+}
+
+// restores the cookies, opens probeURL and tells whether the site shows the
+// loggedIn selector, or the loginForm one because the session has expired
+func (s *browserSession) restore(ctx context.Context, probeURL, loggedIn, loginForm string) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+	content, err := readSession(s.path, s.secret)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		slog.Warn(s.name+": Stored session not readable, logging in", "error", err)
+		return false, nil
+	}
+
+	var params []*network.CookieParam
+	for _, c := range content.Cookies {
+		param := &network.CookieParam{Name: c.Name, Value: c.Value, Domain: c.Domain, Path: c.Path,
+			Secure: c.Secure, HTTPOnly: c.HTTPOnly, SameSite: network.CookieSameSite(c.SameSite)}
+		if c.Expires > 0 {
+			expires := cdp.TimeSinceEpoch(time.Unix(int64(c.Expires), 0))
+			param.Expires = &expires
+		}
+		params = append(params, param)
+	}
+	if len(params) == 0 {
+		return false, nil
+	}
+	if err := network.SetCookies(params).Do(ctx); err != nil {
+		return false, err
+	}
+	if err := chromedp.Navigate(probeURL).Do(ctx); err != nil {
+		return false, err
+	}
+
+	// 1 when logged in, 2 when the login form is shown
+	selectors, _ := json.Marshal([]string{loggedIn, loginForm})
+	probe := fmt.Sprintf(`(function (s) { return document.querySelector(s[0]) ? 1 : (document.querySelector(s[1]) ? 2 : 0); })(%s)`, selectors)
+	for deadline := time.Now().Add(sessionProbeTimeout); time.Now().Before(deadline); {
+		var state int
+		if err := chromedp.Evaluate(probe, &state).Do(ctx); err == nil && state != 0 {
+			if state == 1 {
+				slog.Info(s.name+": Session restored, login skipped", "saved", content.SavedAt.Format(time.RFC3339))
+				s.restored = true
+				return true, nil
+			}
+			break
+		}
+		if err := chromedp.Sleep(250 * time.Millisecond).Do(ctx); err != nil {
+			return false, err
+		}
+	}
+	slog.Info(s.name + ": Stored session expired, logging in")
+	return false, nil
+}
+
+// stores the cookies of the browser after a successful login
+func (s *browserSession) save(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	cookies, err := storage.GetCookies().Do(ctx)
+	if err != nil {
+		return err
+	}
+	content := sessionContent{SavedAt: time.Now()}
+	for _, c := range cookies {
+		cookie := sessionCookie{Name: c.Name, Value: c.Value, Domain: c.Domain, Path: c.Path,
+			Secure: c.Secure, HTTPOnly: c.HTTPOnly, SameSite: string(c.SameSite)}
+		if !c.Session {
+			cookie.Expires = c.Expires
+		}
+		content.Cookies = append(content.Cookies, cookie)
+	}
+	if err := writeSession(s.path, s.secret, content); err != nil {
+		return err
+	}
+	slog.Info(s.name+": Session saved", "cookies", len(content.Cookies))
+	return nil
+}
+
+// removes a restored session when the fetch using it failed, it may be only
+// partly valid, so the next fetch logs in again. A session not used is kept,
+// a mistyped password must not drop it.
+func (s *browserSession) forget() {
+	if s == nil || !s.restored {
+		return
+	}
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		slog.Warn(s.name+": Failed to remove session", "error", err)
+	}
+}
+
+// logs in with the stored session when the site still accepts it, else with
+// the login tasks, and then stores the session for the next fetch
+func loginWithSession(session *browserSession, probeURL, loggedIn, loginForm string, login chromedp.Tasks) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		restored, err := session.restore(ctx, probeURL, loggedIn, loginForm)
+		if err != nil {
+			return err
+		}
+		if !restored {
+			if err := login.Do(ctx); err != nil {
+				return err
+			}
+		}
+		reportProgress(ctx, fetchProgress{Stage: stageLoggedIn})
+		if err := session.save(ctx); err != nil {
+			slog.Warn("Failed to save session", "error", err)
+		}
+		return nil
+	})
+}
+
+// encrypts the session with a key derived from the secret and writes it
+func writeSession(path string, secret string, content sessionContent) error {
+	header, err := newVaultHeader(1)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	file, err := sealVaultFile(header, header.deriveKey(secret), plain)
+	if err != nil {
+		return err
+	}
+	return file.write(path)
+}
+
+// reads a session written by writeSession, without the cookies already expired
+func readSession(path string, secret string) (sessionContent, error) {
+	var content sessionContent
+	file, err := readVaultFile(path, "session")
+	if err != nil {
+		return content, err
+	}
+	plain, err := file.open(file.Header.deriveKey(secret))
+	if err != nil {
+		return content, fmt.Errorf("session saved with another password")
+	}
+	if err := json.Unmarshal(plain, &content); err != nil {
+		return content, fmt.Errorf("invalid session content: %v", err)
+	}
+
+	now := float64(time.Now().Unix())
+	valid := content.Cookies[:0]
+	for _, c := range content.Cookies {
+		if c.Expires == 0 || c.Expires > now {
+			valid = append(valid, c)
+		}
+	}
+	content.Cookies = valid
+	return content, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSessions writes and reads an encrypted session file
+func TestSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timenet.json")
+
+	content := sessionContent{SavedAt: time.Now(), Cookies: []sessionCookie{
+		{Name: "gpi_session", Value: "ok", Domain: "timenet.example.com", Path: "/"},
+		{Name: "remember", Value: "yes", Domain: "timenet.example.com", Path: "/", Expires: float64(time.Now().Add(time.Hour).Unix())},
+		{Name: "old", Value: "gone", Domain: "timenet.example.com", Path: "/", Expires: float64(time.Now().Add(-time.Hour).Unix())},
+	}}
+	if err := writeSession(path, "timenet-secret", content); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if strings.Contains(string(raw), "gpi_session") {
+		t.Errorf("cookies stored in clear")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm().String(); mode != "-rw-------" {
+		t.Errorf("got file mode %s, expected -rw-------", mode)
+	}
+
+	stored, err := readSession(path, "timenet-secret")
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var names []string
+	for _, c := range stored.Cookies {
+		names = append(names, c.Name)
+	}
+	if got := strings.Join(names, " "); got != "gpi_session remember" {
+		t.Errorf("got cookies %s, expected gpi_session remember without the expired one", got)
+	}
+
+	_, err = readSession(path, "other-secret")
+	if err == nil || err.Error() != "session saved with another password" {
+		t.Errorf("got %v reading with another password, expected session saved with another password", err)
+	}
+}
+
+// TestKimaiHomeURL tests the page restored sessions are checked on, next to
+// the configured Kimai URL
+func TestKimaiHomeURL(t *testing.T) {
+	testCases := []struct {
+		url      string
+		expected string
+	}{
+		{"https://kimai.itk-spain.com/index.php", "https://kimai.itk-spain.com/core/kimai.php"},
+		{"https://example.com/kimai/index.php?a=login", "https://example.com/kimai/core/kimai.php"},
+		{"https://example.com/kimai/", "https://example.com/kimai/core/kimai.php"},
+		{"https://example.com", "https://example.com/core/kimai.php"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			if got := (KimaiConfig{URL: tc.url}).homeURL(); got != tc.expected {
+				t.Errorf("got %s, expected %s", got, tc.expected)
+			}
+		})
+	}
+}
+
+// TestSessionsOptIn tests that sessions are only kept when turned on
+func TestSessionsOptIn(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+
+	config = defaultConfig()
+	if session := newBrowserSession("Timenet", "timenet-secret"); session != nil {
+		t.Errorf("session kept by default")
+	}
+	config.KeepSessions = true
+	sessionDir = t.TempDir()
+	t.Cleanup(func() { sessionDir = "" })
+	if session := newBrowserSession("Timenet", "timenet-secret"); session == nil {
+		t.Errorf("session not kept with keep_sessions")
+	}
+}
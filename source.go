@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yosssi/gohtml"
+)
+
+// SourceKind tells BuildSummary how to use the data of a source
+type SourceKind int
+
+const (
+	AttendanceSource SourceKind = iota // clock-in based tracker, like Timenet
+	TimesheetSource                    // booked entries tracker, like Kimai
+)
+
+// TimeSource is a remote time tracker timo can fetch data from.
+// Attendance sources must parse into TimenetData and timesheet
+// sources into KimaiData, so that BuildSummary can compare them.
+type TimeSource interface {
+	Name() string
+	Fetch(ctx context.Context, r FetchRange) (string, error)
+	Parse(raw *string) (any, error)
+}
+
+// FetchRange is the span of months to fetch, both months included
+type FetchRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// returns the range from January 1st of the current year to the current month
+func defaultFetchRange() FetchRange {
+	now := time.Now()
+	return FetchRange{
+		From: time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.Local),
+		To:   time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local),
+	}
+}
+
+// parses a month in format YYYY-MM, e.g. "2024-12"
+func parseFetchMonth(value string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01", strings.TrimSpace(value), time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid month '%s' (expected format like 2024-12)", value)
+	}
+	return t, nil
+}
+
+// checks the range is in the right order and does not end in the future
+func (r FetchRange) validate() error {
+	if r.From.After(r.To) {
+		return fmt.Errorf("fetch range starts (%s) after it ends (%s)", r.From.Format("2006-01"), r.To.Format("2006-01"))
+	}
+	if monthsBetween(r.To, time.Now()) < 0 {
+		return fmt.Errorf("fetch range ends in the future (%s)", r.To.Format("2006-01"))
+	}
+	return nil
+}
+
+// returns how many months are in the range, both ends included
+func (r FetchRange) months() int {
+	return monthsBetween(r.From, r.To) + 1
+}
+
+func (r FetchRange) String() string {
+	return fmt.Sprintf("%s → %s", r.From.Format("Jan 2006"), r.To.Format("Jan 2006"))
+}
+
+// returns the number of months from a to b, negative if b is before a
+func monthsBetween(a, b time.Time) int {
+	return (b.Year()-a.Year())*12 + int(b.Month()) - int(a.Month())
+}
+
+// Credentials holds everything typed in the login form
+type Credentials struct {
+	TimenetPassword string `json:"timenet_password"`
+	KimaiID         string `json:"kimai_id"`
+	KimaiPassword   string `json:"kimai_password"`
+}
+
+// partialFetchError is returned by Fetch with the data of the months read
+// before a step failed, fetchSource parses and stores them anyway
+type partialFetchError struct {
+	Fetched FetchRange // months in the data returned
+	Wanted  int        // months in the range asked for
+	Err     error
+}
+
+func (e *partialFetchError) Error() string {
+	return fmt.Sprintf("%d/%d months fetched (%v)", e.Fetched.months(), e.Wanted, e.Err)
+}
+
+func (e *partialFetchError) Unwrap() error { return e.Err }
+
+// a sourceFactory builds a TimeSource from the user credentials or
+// returns an error explaining why the source cannot be used
+type sourceFactory func(creds Credentials) (TimeSource, error)
+
+type registeredSource struct {
+	name    string // same as TimeSource.Name()
+	kind    SourceKind
+	factory sourceFactory
+}
+
+// sources are kept in registration order so that the UI is stable
+var sourceRegistry []registeredSource
+
+// registerSource makes a new time tracker available to timo
+func registerSource(name string, kind SourceKind, factory sourceFactory) {
+	sourceRegistry = append(sourceRegistry, registeredSource{name: name, kind: kind, factory: factory})
+}
+
+// returns the names of all registered sources of the given kind
+func registeredSourceNames(kind SourceKind) []string {
+	var names []string
+	for _, r := range sourceRegistry {
+		if r.kind == kind {
+			names = append(names, r.name)
+		}
+	}
+	return names
+}
+
+// builds all registered sources with the given credentials
+func newSources(creds Credentials) ([]TimeSource, error) {
+	if err := creds.validate(); err != nil {
+		return nil, err
+	}
+	hideInLog(creds.TimenetPassword, creds.KimaiPassword)
+
+	var sources []TimeSource
+	for _, r := range sourceRegistry {
+		src, err := r.factory(creds)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// returns the key used to store the data of a source, e.g. "timenet"
+func sourceKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// fetchSource scrapes, parses and stores the data of one source.
+// Only the months of the range that can still change are fetched and
+// saved in the history, returns how many months were fetched and the
+// issues found in the parsed data. Data with parse errors is not stored.
+// When the source stopped half way, the months it read are stored and a
+// *partialFetchError is returned with them.
+func fetchSource(ctx context.Context, src TimeSource, r FetchRange) (int, ParseDiagnostics, error) {
+
+	r, needed := incrementalRange(r, history.storedMonths(src.Name()))
+	if !needed {
+		slog.Info("All months already stored and final, nothing to fetch", "source", src.Name())
+		return 0, nil, nil
+	}
+
+	// SCRAPING
+	ctx = withProgressSource(ctx, src.Name(), r.months())
+	reportProgress(ctx, fetchProgress{Stage: stageStarted})
+	slog.Info("Starting scraping", "source", src.Name(), "from", r.From.Format("2006-01"), "to", r.To.Format("2006-01"))
+	raw, err := src.Fetch(ctx, r)
+	// a cancelled fetch stores nothing, not even the months already read
+	if ctx.Err() != nil {
+		slog.Info("Fetch cancelled", "source", src.Name())
+		return 0, nil, fmt.Errorf("fetch cancelled")
+	}
+	var partial *partialFetchError
+	if errors.As(err, &partial) {
+		slog.Warn("Scraping stopped, keeping the months fetched", "source", src.Name(), "error", err)
+		r = partial.Fetched
+	} else if err != nil {
+		slog.Error("Failed to scrape", "source", src.Name(), "error", err)
+		return 0, nil, err
+	}
+
+	// DEBUG
+	if false {
+		raw = gohtml.Format(raw)
+		os.WriteFile("dump.html", []byte(raw), 0644)
+	}
+
+	// PARSE AND SAVE IN THE LOCAL HISTORY
+	reportProgress(ctx, fetchProgress{Stage: stageParsing})
+	slog.Info("Starting data parsing", "source", src.Name())
+	data, err := src.Parse(&raw)
+	if err != nil {
+		slog.Error("Failed to parse", "source", src.Name(), "error", err)
+		return 0, nil, err
+	}
+
+	// a layout change in the site gives blank or wrong fields, never store them
+	diags := checkSourceData(src.Name(), data, r)
+	diags.log()
+	if diags.hasErrors() {
+		return 0, diags, fmt.Errorf("data not stored, the site layout may have changed (%s)", diags.summary())
+	}
+
+	err = history.saveSourceData(src.Name(), data, r)
+	if err != nil {
+		return 0, diags, fmt.Errorf("failed to save history: %v", err)
+	}
+	snapshot, err := json.Marshal(data)
+	if err != nil {
+		slog.Warn("Failed to snapshot fetched data", "source", src.Name(), "error", err)
+	}
+	err = history.addFetch(fetchRecord{
+		Source:    src.Name(),
+		From:      r.From.Format("2006-01"),
+		To:        r.To.Format("2006-01"),
+		FetchedAt: time.Now(),
+		Months:    r.months(),
+		Snapshot:  snapshot,
+	})
+	if err != nil {
+		slog.Warn("Failed to record fetch in history", "source", src.Name(), "error", err)
+	}
+	slog.Info("Data saved in history", "source", src.Name())
+	reportProgress(ctx, fetchProgress{Stage: stageSaved})
+
+	if partial != nil {
+		return r.months(), diags, partial
+	}
+	return r.months(), diags, nil
+}
+
+func init() {
+	registerSource("Timenet", AttendanceSource, func(creds Credentials) (TimeSource, error) {
+		if creds.TimenetPassword == "" {
+			return nil, fmt.Errorf("Timenet password is blank, use valid password")
+		}
+		return &timenetSource{password: creds.TimenetPassword}, nil
+	})
+	registerSource("Kimai", TimesheetSource, func(creds Credentials) (TimeSource, error) {
+		// Kimai 2 instances are read from the REST API, no Chromium needed
+		if kimaiAPIURL != "" {
+			if creds.KimaiPassword == "" {
+				return nil, fmt.Errorf("Kimai API token is blank, use valid credentials")
+			}
+			return newKimaiAPISource(kimaiAPIURL, creds.KimaiID, creds.KimaiPassword), nil
+		}
+		if creds.KimaiID == "" || creds.KimaiPassword == "" {
+			return nil, fmt.Errorf("Kimai credentials are blank, use valid credentials")
+		}
+		return &kimaiSource{id: creds.KimaiID, password: creds.KimaiPassword}, nil
+	})
+}
+
+// TIMENET SOURCE
+type timenetSource struct {
+	password string
+}
+
+func (s *timenetSource) Name() string { return "Timenet" }
+
+func (s *timenetSource) Fetch(ctx context.Context, r FetchRange) (string, error) {
+	if config.Timenet.Mode == "network" {
+		return captureTimenet(ctx, s.password, r)
+	}
+	html, err := scrapeTimenet(ctx, s.password, r)
+	if html == "" {
+		return "", err
+	}
+	cleanHTML(&html)
+	return html, err
+}
+
+func (s *timenetSource) Parse(raw *string) (any, error) {
+	if config.Timenet.Mode == "network" {
+		return timenetNetworkParse(raw)
+	}
+	return timenetParse(raw)
+}
+
+// KIMAI SOURCE
+type kimaiSource struct {
+	id       string
+	password string
+}
+
+func (s *kimaiSource) Name() string { return "Kimai" }
+
+func (s *kimaiSource) Fetch(ctx context.Context, r FetchRange) (string, error) {
+	return scrapeKimai(ctx, s.id, s.password, r)
+}
+
+func (s *kimaiSource) Parse(raw *string) (any, error) {
+	return kimaiParse(raw)
+}
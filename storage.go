@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// number of months before the current one that are always fetched again,
+// older months are considered final once they are stored
+var refreshMonths int = 1
+
+// the local history of all fetched data, opened once at start up
+var history *historyStore
+
+// historyStore keeps all fetched data in a bbolt database in the user config
+// directory, ~/.config/timo/history.db in Linux. There is one bucket per source
+// holding one row per Timenet month and day or per Kimai entry, plus one
+// bucket with one row per fetch.
+//
+//	timenet/summary/latest          -> TimenetData without months
+//	timenet/months/2025-03          -> TimenetMonthlyData without days
+//	timenet/days/2025-03-04         -> TimenetDailyData
+//	kimai/summary/latest            -> KimaiData without entries
+//	kimai/months/2025-03            -> time of the fetch
+//	kimai/entries/2025-03-04#0001   -> KimaiMonthlyData, or
+//	kimai/entries/2025-03-04#09:00#123 when Kimai shows the entry ID
+//	fetches/2025-03-04T10:00:00Z#kimai -> fetchRecord
+//	meta/schema_version             -> version of the data model
+type historyStore struct {
+	db *bolt.DB
+}
+
+// one row per fetch
+type fetchRecord struct {
+	Source    string    `json:"source"`
+	From      string    `json:"from"` // YYYY-MM
+	To        string    `json:"to"`   // YYYY-MM
+	FetchedAt time.Time `json:"fetched_at"`
+	Months    int       `json:"months"`
+
+	// the parsed data as fetched, used to tell what changed between fetches
+	Snapshot json.RawMessage `json:"snapshot,omitempty"`
+}
+
+var (
+	bucketSummary = []byte("summary")
+	bucketMonths  = []byte("months")
+	bucketDays    = []byte("days")
+	bucketEntries = []byte("entries")
+	bucketFetches = []byte("fetches")
+	keyLatest     = []byte("latest")
+)
+
+// returns the path of the history database, ~/.config/timo/history.db in Linux
+func historyPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "timo", "history.db"), nil
+}
+
+// opens (or creates) the history database at the given path
+func openHistory(path string) (*historyStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history %s: %v", path, err)
+	}
+	slog.Info("History database opened", "path", path)
+	h := &historyStore{db: db}
+	if err := h.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate history %s: %v", path, err)
+	}
+	return h, nil
+}
+
+func (h *historyStore) Close() error {
+	return h.db.Close()
+}
+
+// returns the nested bucket of a source, creating it when writable
+func sourceBucket(tx *bolt.Tx, name string, bucket []byte) (*bolt.Bucket, error) {
+	if !tx.Writable() {
+		root := tx.Bucket([]byte(sourceKey(name)))
+		if root == nil {
+			return nil, nil
+		}
+		return root.Bucket(bucket), nil
+	}
+	root, err := tx.CreateBucketIfNotExists([]byte(sourceKey(name)))
+	if err != nil {
+		return nil, err
+	}
+	return root.CreateBucketIfNotExists(bucket)
+}
+
+func putJSON(b *bolt.Bucket, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), data)
+}
+
+// deletes all keys starting with prefix
+func deletePrefix(b *bolt.Bucket, prefix string) error {
+	c := b.Cursor()
+	for k, _ := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = c.Seek([]byte(prefix)) {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saves freshly fetched data of a source, replacing the stored fetched months
+func (h *historyStore) saveSourceData(name string, data any, r FetchRange) error {
+	switch fresh := data.(type) {
+	case TimenetData:
+		return h.saveTimenet(name, fresh)
+	case KimaiData:
+		return h.saveKimai(name, fresh, r)
+	}
+	return fmt.Errorf("unsupported data type %T for source %s", data, name)
+}
+
+func (h *historyStore) saveTimenet(name string, data TimenetData) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		summary, err := sourceBucket(tx, name, bucketSummary)
+		if err != nil {
+			return err
+		}
+		months, err := sourceBucket(tx, name, bucketMonths)
+		if err != nil {
+			return err
+		}
+		days, err := sourceBucket(tx, name, bucketDays)
+		if err != nil {
+			return err
+		}
+
+		// yearly totals come from the newest month, keep the stored ones when
+		// only older months have been fetched
+		newest := ""
+		if k, _ := months.Cursor().Last(); k != nil {
+			newest = string(k)
+		}
+		if len(data.MonthlyData) > 0 && data.MonthlyData[0].Month >= newest {
+			header := data
+			header.MonthlyData = nil
+			if err := putJSON(summary, string(keyLatest), header); err != nil {
+				return err
+			}
+		}
+
+		for _, month := range data.MonthlyData {
+			key := month.Month
+			if key == "" {
+				slog.Warn("Skipping Timenet month with unknown date")
+				continue
+			}
+			if err := deletePrefix(days, key+"-"); err != nil {
+				return err
+			}
+			for _, day := range month.DailyData {
+				if err := putJSON(days, day.Date, day); err != nil {
+					return err
+				}
+			}
+			month.DailyData = nil
+			if err := putJSON(months, key, month); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (h *historyStore) saveKimai(name string, data KimaiData, r FetchRange) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		summary, err := sourceBucket(tx, name, bucketSummary)
+		if err != nil {
+			return err
+		}
+		months, err := sourceBucket(tx, name, bucketMonths)
+		if err != nil {
+			return err
+		}
+		entries, err := sourceBucket(tx, name, bucketEntries)
+		if err != nil {
+			return err
+		}
+
+		header := data
+		header.MonthlyData = nil
+		header.FetchedMonths = nil
+		if err := putJSON(summary, string(keyLatest), header); err != nil {
+			return err
+		}
+
+		// all entries of the fetched months are replaced
+		for _, key := range rangeMonthKeys(r) {
+			if err := deletePrefix(entries, key+"-"); err != nil {
+				return err
+			}
+			if err := putJSON(months, key, data.FetchedAt); err != nil {
+				return err
+			}
+		}
+		// entries with an ID keep one row whatever fetch brings them
+		for i, entry := range data.MonthlyData {
+			key := fmt.Sprintf("%s#%04d", entry.Date, i)
+			if entry.ID != 0 {
+				key = fmt.Sprintf("%s#%s#%d", entry.Date, entry.In, entry.ID)
+			}
+			if err := putJSON(entries, key, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// loads all stored Timenet months of a source, newest month first
+func (h *historyStore) loadTimenet(name string) (*TimenetData, error) {
+	var data TimenetData
+	err := h.db.View(func(tx *bolt.Tx) error {
+		summary, _ := sourceBucket(tx, name, bucketSummary)
+		months, _ := sourceBucket(tx, name, bucketMonths)
+		days, _ := sourceBucket(tx, name, bucketDays)
+		if summary == nil || months == nil || days == nil {
+			return fmt.Errorf("no %s data stored yet", name)
+		}
+		if err := json.Unmarshal(summary.Get(keyLatest), &data); err != nil {
+			return err
+		}
+
+		c := months.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var month TimenetMonthlyData
+			if err := json.Unmarshal(v, &month); err != nil {
+				return err
+			}
+			prefix := []byte(string(k) + "-")
+			dc := days.Cursor()
+			for dk, dv := dc.Seek(prefix); dk != nil && bytes.HasPrefix(dk, prefix); dk, dv = dc.Next() {
+				var day TimenetDailyData
+				if err := json.Unmarshal(dv, &day); err != nil {
+					return err
+				}
+				month.DailyData = append(month.DailyData, day)
+			}
+			data.MonthlyData = append(data.MonthlyData, month)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// loads the stored Kimai entries of a source, only those of one month
+// (YYYY-MM) when month is not blank, ordered by date
+func (h *historyStore) loadKimai(name string, month string) (*KimaiData, error) {
+	var data KimaiData
+	err := h.db.View(func(tx *bolt.Tx) error {
+		summary, _ := sourceBucket(tx, name, bucketSummary)
+		months, _ := sourceBucket(tx, name, bucketMonths)
+		entries, _ := sourceBucket(tx, name, bucketEntries)
+		if summary == nil || months == nil || entries == nil {
+			return fmt.Errorf("no %s data stored yet", name)
+		}
+		if err := json.Unmarshal(summary.Get(keyLatest), &data); err != nil {
+			return err
+		}
+		months.ForEach(func(k, v []byte) error {
+			data.FetchedMonths = append(data.FetchedMonths, string(k))
+			return nil
+		})
+
+		prefix := []byte(month)
+		c := entries.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var entry KimaiMonthlyData
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			data.MonthlyData = append(data.MonthlyData, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// returns the months already stored for a source, keyed as YYYY-MM
+func (h *historyStore) storedMonths(name string) map[string]bool {
+	stored := make(map[string]bool)
+	h.db.View(func(tx *bolt.Tx) error {
+		months, _ := sourceBucket(tx, name, bucketMonths)
+		if months == nil {
+			return nil
+		}
+		return months.ForEach(func(k, v []byte) error {
+			stored[string(k)] = true
+			return nil
+		})
+	})
+	return stored
+}
+
+// adds one row to the fetches bucket
+func (h *historyStore) addFetch(rec fetchRecord) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		fetches, err := tx.CreateBucketIfNotExists(bucketFetches)
+		if err != nil {
+			return err
+		}
+		key := rec.FetchedAt.UTC().Format(time.RFC3339Nano) + "#" + sourceKey(rec.Source)
+		return putJSON(fetches, key, rec)
+	})
+}
+
+// returns the last n fetches of a source, newest first
+func (h *historyStore) lastFetches(name string, n int) ([]fetchRecord, error) {
+	var records []fetchRecord
+	err := h.db.View(func(tx *bolt.Tx) error {
+		fetches := tx.Bucket(bucketFetches)
+		if fetches == nil {
+			return nil
+		}
+		suffix := []byte("#" + sourceKey(name))
+		c := fetches.Cursor()
+		for k, v := c.Last(); k != nil && len(records) < n; k, v = c.Prev() {
+			if !bytes.HasSuffix(k, suffix) {
+				continue
+			}
+			var rec fetchRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			records = append(records, rec)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// imports the latest JSON files written by older timo versions in the OS
+// temp folder, only for sources that have nothing stored yet. Those files
+// always use the first version of the data model.
+func (h *historyStore) importLegacyJSON() {
+	for _, r := range sourceRegistry {
+		if len(h.storedMonths(r.name)) > 0 {
+			continue
+		}
+		prefix := sourceKey(r.name) + "_data_"
+		var err error
+		switch r.kind {
+		case AttendanceSource:
+			var legacy *timenetDataV1
+			if legacy, err = readLatestJSON[timenetDataV1](prefix); err == nil {
+				err = h.saveTimenet(r.name, legacy.migrate())
+			}
+		case TimesheetSource:
+			var legacy *kimaiDataV1
+			if legacy, err = readLatestJSON[kimaiDataV1](prefix); err == nil {
+				data := legacy.migrate()
+				err = h.saveKimai(r.name, data, kimaiDataRange(data))
+			}
+		}
+		if err == nil {
+			slog.Info("Imported legacy JSON into history", "source", r.name)
+		}
+	}
+}
+
+// returns the range of months covered by the entries of a Kimai JSON file
+func kimaiDataRange(data KimaiData) FetchRange {
+	var keys []string
+	for _, entry := range data.MonthlyData {
+		keys = append(keys, kimaiMonthKey(entry))
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		now := time.Now()
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+		return FetchRange{From: month, To: month}
+	}
+	from, _ := parseFetchMonth(keys[0])
+	to, _ := parseFetchMonth(keys[len(keys)-1])
+	return FetchRange{From: from, To: to}
+}
+
+// readLatestJSON finds and reads the most recent JSON file with the given prefix
+func readLatestJSON[T any](prefix string) (*T, error) {
+	tempDir := os.TempDir()
+	pattern := filepath.Join(tempDir, prefix+"*.json")
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for JSON files: %v", err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no JSON files found in %s with prefix %s", tempDir, prefix)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		infoI, errI := os.Stat(matches[i])
+		infoJ, errJ := os.Stat(matches[j])
+		if errI != nil || errJ != nil {
+			return false
+		}
+		return infoI.ModTime().After(infoJ.ModTime())
+	})
+	latestFile := matches[0]
+	slog.Info("Loading latest JSON file", "filename", latestFile)
+	jsonData, err := os.ReadFile(latestFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %v", latestFile, err)
+	}
+	var data T
+	err = json.Unmarshal(jsonData, &data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON from %s: %v", latestFile, err)
+	}
+	return &data, nil
+}
+
+// loads the data of the first attendance source, the reference for worked time
+func loadAttendance() (*TimenetData, error) {
+	attendance := registeredSourceNames(AttendanceSource)
+	if len(attendance) == 0 {
+		return nil, fmt.Errorf("no attendance source registered")
+	}
+	return history.loadTimenet(attendance[0])
+}
+
+// loads the entries of one month (YYYY-MM) from all timesheet sources,
+// all months when blank
+func loadTimesheetEntries(monthKey string) []KimaiMonthlyData {
+	var entries []KimaiMonthlyData
+	for _, name := range registeredSourceNames(TimesheetSource) {
+		data, err := history.loadKimai(name, monthKey)
+		if err != nil {
+			slog.Warn("No data available for source", "source", name, "error", err)
+			continue
+		}
+		entries = append(entries, data.MonthlyData...)
+	}
+	return entries
+}
+
+// returns the index of a month (YYYY-MM) in the Timenet data, -1 when missing
+func monthIndexOf(data *TimenetData, monthKey string) int {
+	for i, month := range data.MonthlyData {
+		if month.Month == monthKey {
+			return i
+		}
+	}
+	return -1
+}
+
+// returns the month key in format YYYY-MM of a Kimai entry dated YYYY-MM-DD
+func kimaiMonthKey(entry KimaiMonthlyData) string {
+	if len(entry.Date) < 7 {
+		return ""
+	}
+	return entry.Date[0:7]
+}
+
+// returns all months of the range in format YYYY-MM
+func rangeMonthKeys(r FetchRange) []string {
+	var keys []string
+	for month := r.From; !month.After(r.To); month = month.AddDate(0, 1, 0) {
+		keys = append(keys, month.Format("2006-01"))
+	}
+	return keys
+}
+
+// returns the part of the fetch range that still needs fetching: a month is
+// final, and never fetched again, when it is already stored and older than
+// the refresh window. Returns false when all months in the range are final.
+func incrementalRange(r FetchRange, stored map[string]bool) (FetchRange, bool) {
+	now := time.Now()
+	for month := r.From; !month.After(r.To); month = month.AddDate(0, 1, 0) {
+		final := stored[month.Format("2006-01")] && monthsBetween(month, now) > refreshMonths
+		if !final {
+			return FetchRange{From: month, To: r.To}, true
+		}
+	}
+	return r, false
+}
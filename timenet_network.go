@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// TIMENET NETWORK CAPTURE
+// With mode: network in the config, timo goes through the same login and
+// month navigation but reads the JSON the checks page loads from the Timenet
+// backend instead of the rendered cards. Faster and not broken by a change in
+// the page layout, only by a change in the backend. The URL pattern must
+// match the request loading each month, the fields tell where each value is.
+// Responses are matched to their month by the dates of their days, so late,
+// repeated or out of order responses do not shift the months.
+
+// how long to wait for the backend to answer after opening a month
+const timenetResponseTimeout = 10 * time.Second
+
+// captures the month JSON of all months in the fetch range and returns them
+// as a JSON list, newest month first as scrapeTimenet does
+func captureTimenet(parent context.Context, password string, r FetchRange) (string, error) {
+	sel := config.Timenet.Selectors
+	pattern, err := regexp.Compile(config.Timenet.Network.URLPattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid Timenet url_pattern: %v", err)
+	}
+	ctx, cancel := newChromeContext(parent, config.Timenet.Timeout)
+	defer cancel()
+
+	now := time.Now()
+	monthsToSkip := monthsBetween(r.To, now)
+	monthsToScrape := monthsBetween(r.From, r.To) + 1
+	slog.Info("Timenet: Capturing months in fetch range",
+		"from", r.From.Format("2006-01"), "to", r.To.Format("2006-01"),
+		"monthsToSkip", monthsToSkip, "monthsToScrape", monthsToScrape, "pattern", pattern.String())
+
+	// requests matching the pattern, sent once fully loaded. Listeners must
+	// not block, the bodies are read from the actions below.
+	var mu sync.Mutex
+	matching := make(map[network.RequestID]string)
+	loaded := make(chan network.RequestID, 64)
+	chromedp.ListenTarget(ctx, func(ev any) {
+		switch ev := ev.(type) {
+		case *network.EventResponseReceived:
+			// an expired session may get a 401 before the login page
+			if ev.Response.Status == 200 && pattern.MatchString(ev.Response.URL) {
+				mu.Lock()
+				matching[ev.RequestID] = ev.Response.URL
+				mu.Unlock()
+			}
+		case *network.EventLoadingFinished:
+			mu.Lock()
+			url, found := matching[ev.RequestID]
+			delete(matching, ev.RequestID)
+			mu.Unlock()
+			if !found {
+				return
+			}
+			select {
+			case loaded <- ev.RequestID:
+				slog.Info("Timenet: Captured backend response", "url", url)
+			default:
+				slog.Warn("Timenet: Too many backend responses, one dropped", "url", url)
+			}
+		}
+	})
+
+	// returns the body of the given month, e.g. "2025-03", keeping the other
+	// months received meanwhile for later
+	captured := make(map[string][]byte)
+	waitMonth := func(ctx context.Context, month string) ([]byte, error) {
+		timeout := time.After(timenetResponseTimeout)
+		for {
+			if body, found := captured[month]; found {
+				return body, nil
+			}
+			select {
+			case id := <-loaded:
+				body, err := network.GetResponseBody(id).Do(ctx)
+				if err != nil {
+					return nil, err
+				}
+				if !json.Valid(body) {
+					return nil, fmt.Errorf("backend response is not JSON, check url_pattern")
+				}
+				key := jsonMonth(body)
+				if key == "" {
+					slog.Warn("Timenet: Backend response without dated days, ignored")
+					continue
+				}
+				captured[key] = body
+			case <-timeout:
+				return nil, fmt.Errorf("no backend response for %s matching '%s'", month, pattern)
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	var months []json.RawMessage
+	session := newBrowserSession("Timenet", password)
+	err = chromedp.Run(ctx,
+		network.Enable(),
+		loginWithSession(session, config.Timenet.checksURL(), sel.MonthHeader, sel.Password, timenetLogin(password)),
+
+		// the checks page loads the current month, each click the previous one
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			for i := 0; i < monthsToSkip+monthsToScrape; i++ {
+				month := time.Date(now.Year(), now.Month()-time.Month(i), 1, 0, 0, 0, 0, time.Local).Format("2006-01")
+				body, err := waitMonth(ctx, month)
+				if err != nil {
+					slog.Error("Timenet: Failed to capture month", "month", month, "error", err)
+					return err
+				}
+				if i >= monthsToSkip {
+					months = append(months, body)
+					reportProgress(ctx, fetchProgress{Stage: stageMonth, Month: len(months)})
+				}
+				if i == monthsToSkip+monthsToScrape-1 {
+					break
+				}
+				if err := previousMonth(sel, monthsBefore(time.Now(), i+1)).Do(ctx); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	)
+	// a cancelled fetch says nothing about the stored session, it is kept
+	if err != nil && parent.Err() != nil {
+		return "", parent.Err()
+	}
+	if err != nil && len(months) == 0 {
+		session.forget()
+		return "", fmt.Errorf("failed to capture Timenet backend: %v", err)
+	}
+
+	raw, jsonErr := json.Marshal(months)
+	if jsonErr != nil {
+		return "", jsonErr
+	}
+
+	// months already captured are kept, from the last one of the range backwards
+	if err != nil {
+		slog.Warn("Timenet: Capture stopped, keeping the months captured", "captured", len(months), "of", monthsToScrape, "error", err)
+		return string(raw), &partialFetchError{
+			Fetched: FetchRange{From: r.To.AddDate(0, 1-len(months), 0), To: r.To},
+			Wanted:  monthsToScrape,
+			Err:     err,
+		}
+	}
+	slog.Info("Timenet network capture successful", "months", len(months))
+	return string(raw), nil
+}
+
+// returns the value at a path like "data.days", nil when missing
+func jsonField(value any, path string) any {
+	if path == "" {
+		return nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// returns a JSON string or number as text, blank for anything else
+func jsonText(value any) string {
+	switch value := value.(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return ""
+}
+
+// converts a JSON time to minutes, numbers are minutes and text is read like
+// a Timenet cell, e.g. "8h 30m"
+func jsonMinutes(issues *ParseDiagnostics, where, field string, value any) int {
+	if minutes, ok := value.(float64); ok {
+		return int(minutes)
+	}
+	return parseCellMinutes(issues, where, field, jsonText(value), false)
+}
+
+// an ISO date, with or without time
+var isoDateRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+
+// converts a JSON date, ISO with or without time or DD/MM/YYYY, to YYYY-MM-DD
+func jsonDate(value any) string {
+	date := jsonText(value)
+	if isoDateRe.MatchString(date) {
+		return date[:10]
+	}
+	return convertDateFormat(date)
+}
+
+// returns the month, e.g. "2025-03", most of the dated days of a captured
+// month belong to, blank when there is none. A calendar grid starts and
+// ends with days of the months around it.
+func jsonMonth(body []byte) string {
+	fields := config.Timenet.Network.Fields
+	var month any
+	if err := json.Unmarshal(body, &month); err != nil {
+		return ""
+	}
+	days, _ := jsonField(month, fields.Days).([]any)
+	counts := make(map[string]int)
+	mostCommon := ""
+	for _, day := range days {
+		date, err := time.Parse("2006-01-02", jsonDate(jsonField(day, fields.Date)))
+		if err != nil {
+			continue
+		}
+		key := date.Format("2006-01")
+		counts[key]++
+		if counts[key] > counts[mostCommon] {
+			mostCommon = key
+		}
+	}
+	return mostCommon
+}
+
+// timenetNetworkParse reads the months captured by captureTimenet
+func timenetNetworkParse(raw *string) (TimenetData, error) {
+	if raw == nil {
+		return TimenetData{}, fmt.Errorf("JSON content is nil")
+	}
+	data := TimenetData{
+		SchemaVersion: dataSchemaVersion,
+		FetchedAt:     time.Now(),
+	}
+
+	var payloads []json.RawMessage
+	if err := json.Unmarshal([]byte(*raw), &payloads); err != nil {
+		return data, fmt.Errorf("invalid Timenet capture: %v", err)
+	}
+	slog.Info("Timenet: Number of months to parse", "count", len(payloads))
+
+	fields := config.Timenet.Network.Fields
+	for i, payload := range payloads {
+		var month any
+		if err := json.Unmarshal(payload, &month); err != nil {
+			return data, fmt.Errorf("invalid Timenet month: %v", err)
+		}
+		if i == 0 {
+			data.ExpectedMinutesInYear = jsonMinutes(&data.issues, "", "yearly expected time", jsonField(month, fields.YearExpected))
+			data.WorkedMinutesInYear = jsonMinutes(&data.issues, "", "yearly worked time", jsonField(month, fields.YearWorked))
+			data.OvertimeMinutesInYear = jsonMinutes(&data.issues, "", "yearly overtime", jsonField(month, fields.YearOvertime))
+		}
+
+		days, ok := jsonField(month, fields.Days).([]any)
+		if !ok {
+			data.issues.add(IssueError, "", "", "no '%s' list in the month JSON", fields.Days)
+			continue
+		}
+
+		// days of the months around, shown by a calendar grid, are left out
+		monthlyData := TimenetMonthlyData{Month: jsonMonth(payload)}
+		for _, day := range days {
+			dailyData := TimenetDailyData{Date: jsonDate(jsonField(day, fields.Date))}
+			if dailyData.Date == "" || !strings.HasPrefix(dailyData.Date, monthlyData.Month) {
+				continue
+			}
+			dailyData.ExpectedMinutes = jsonMinutes(&data.issues, dailyData.Date, "expected time", jsonField(day, fields.Expected))
+			dailyData.WorkedMinutes = jsonMinutes(&data.issues, dailyData.Date, "worked time", jsonField(day, fields.Worked))
+			dailyData.OvertimeMinutes = jsonMinutes(&data.issues, dailyData.Date, "overtime", jsonField(day, fields.Overtime))
+
+			dailyData.DayLabel = jsonText(jsonField(day, fields.DayLabel))
+			dayType, found := classifyDayType(dailyData.DayLabel, dailyData.ExpectedMinutes > 0)
+			if !found && dailyData.DayLabel != "" {
+				data.issues.add(IssueWarning, "", dailyData.Date, "unknown day type '%s', add it to day_types in the config", dailyData.DayLabel)
+			}
+			dailyData.DayType = dayType
+
+			var times []string
+			punches, _ := jsonField(day, fields.Punches).([]any)
+			for _, punch := range punches {
+				times = append(times, jsonText(punch))
+			}
+			dailyData.Punches = parsePunches(&data.issues, dailyData.Date, times)
+
+			// the backend gives no monthly totals, they are the sum of the days
+			monthlyData.ExpectedMinutes += dailyData.ExpectedMinutes
+			monthlyData.WorkedMinutes += dailyData.WorkedMinutes
+			monthlyData.OvertimeMinutes += dailyData.OvertimeMinutes
+			monthlyData.DailyData = append(monthlyData.DailyData, dailyData)
+		}
+		if data.Year == 0 && len(monthlyData.Month) == 7 {
+			data.Year, _ = strconv.Atoi(monthlyData.Month[:4])
+		}
+
+		data.MonthlyData = append(data.MonthlyData, monthlyData)
+		slog.Info("Timenet: Parsed monthly data for month", "month", monthlyData.Month)
+	}
+	return data, nil
+}
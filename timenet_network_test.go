@@ -0,0 +1,123 @@
+package main
+
+import "testing"
+
+// TestTimenetNetworkParse tests timenetNetworkParse with a captured month
+// using numbers, text times, ISO punches and nested yearly totals, starting
+// with a day of the month before as a calendar grid does
+func TestTimenetNetworkParse(t *testing.T) {
+	raw := `[{"year":{"expected":1760,"worked":"1700h","difference":"-60h"},
+		"days":[
+			{"date":"2025-02-28","dayType":"Laborable","expected":480,"worked":480,"difference":0,"checks":[]},
+			{"date":"2025-03-03T00:00:00","dayType":"Laborable","expected":480,"worked":"8h 5m","difference":5,
+				"checks":["2025-03-03T08:55:00","12:30","13:15","17:15"]},
+			{"date":"2025-03-04","dayType":"Laborable","expected":480,"worked":240,"difference":-240,"checks":["09:00"]},
+			{"date":"08/03/2025","dayType":"non working day","expected":null,"worked":0,"difference":0,"checks":[]},
+			{"dayType":"Total"}
+		]},
+		{"days":"none"}]`
+
+	config.Timenet.Network = fakeTimenetNetwork
+	defer func() { config.Timenet.Network = TimenetNetwork{} }()
+	data, err := timenetNetworkParse(&raw)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if errors := data.issues.count(IssueError); errors != 1 {
+		t.Errorf("got %d errors, expected 1 for the month without a days list", errors)
+	}
+	if len(data.MonthlyData) != 1 {
+		t.Fatalf("got %d months, expected 1 with the month without days skipped", len(data.MonthlyData))
+	}
+	if data.Year != 2025 {
+		t.Errorf("got year %d, expected 2025", data.Year)
+	}
+	if data.OvertimeMinutesInYear != -3600 {
+		t.Errorf("got yearly overtime %d, expected -3600 from the nested text", data.OvertimeMinutesInYear)
+	}
+
+	month := data.MonthlyData[0]
+	if month.Month != "2025-03" {
+		t.Errorf("got month %s, expected 2025-03 from most of the days", month.Month)
+	}
+	if month.WorkedMinutes != 725 || month.OvertimeMinutes != -235 {
+		t.Errorf("got monthly worked %d and overtime %d, expected the sums 725 and -235", month.WorkedMinutes, month.OvertimeMinutes)
+	}
+	if len(month.DailyData) != 3 {
+		t.Fatalf("got %d days, expected 3 without the total row and the day of February", len(month.DailyData))
+	}
+
+	monday := month.DailyData[0]
+	if monday.Date != "2025-03-03" {
+		t.Errorf("got date %s from an ISO date with time, expected 2025-03-03", monday.Date)
+	}
+	if monday.WorkedMinutes != 485 {
+		t.Errorf("got %d worked minutes from text, expected 485", monday.WorkedMinutes)
+	}
+	if got := formatPunches(monday.Punches); got != "08:55-12:30 13:15-17:15" {
+		t.Errorf("got punches %s, expected 08:55-12:30 13:15-17:15", got)
+	}
+	if got := formatPunches(month.DailyData[1].Punches); got != "09:00-?" {
+		t.Errorf("got punches %s, expected a missing clock-out 09:00-?", got)
+	}
+
+	saturday := month.DailyData[2]
+	if saturday.Date != "2025-03-08" {
+		t.Errorf("got date %s from DD/MM/YYYY, expected 2025-03-08", saturday.Date)
+	}
+	if saturday.DayType != DayWeekend {
+		t.Errorf("got day type %v, expected weekend", saturday.DayType)
+	}
+}
+
+// TestJSONMonth tests jsonMonth, which matches captured responses to months
+func TestJSONMonth(t *testing.T) {
+	config.Timenet.Network = fakeTimenetNetwork
+	defer func() { config.Timenet.Network = TimenetNetwork{} }()
+
+	testCases := []struct {
+		body     string
+		expected string
+	}{
+		{`{"days":[{"date":"2025-03-01T00:00:00"},{"date":"2025-03-02"}]}`, "2025-03"},
+		{`{"days":[{"dayType":"Total"},{"date":"28/02/2025"}]}`, "2025-02"},
+		{`{"days":[{"date":"2025-02-24"},{"date":"2025-02-28"},{"date":"2025-03-01"},{"date":"2025-03-02"},{"date":"2025-03-03"}]}`, "2025-03"},
+		{`{"days":[]}`, ""},
+		{`{"months":[{"date":"2025-03-01"}]}`, ""},
+		{`[]`, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.body, func(t *testing.T) {
+			if got := jsonMonth([]byte(tc.body)); got != tc.expected {
+				t.Errorf("got '%s', expected '%s'", got, tc.expected)
+			}
+		})
+	}
+}
+
+// TestTimenetNetworkConfig tests that network mode is only valid once the
+// backend of the tenant is configured
+func TestTimenetNetworkConfig(t *testing.T) {
+	testCases := []struct {
+		name    string
+		network TimenetNetwork
+		valid   bool
+	}{
+		{"defaults", defaultConfig().Timenet.Network, false},
+		{"configured", fakeTimenetNetwork, true},
+		{"invalid pattern", TimenetNetwork{URLPattern: "(", Fields: fakeTimenetNetwork.Fields}, false},
+		{"no fields", TimenetNetwork{URLPattern: fakeTimenetNetwork.URLPattern}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.Timenet.Mode = "network"
+			cfg.Timenet.Network = tc.network
+			if err := cfg.validate(); (err == nil) != tc.valid {
+				t.Errorf("got error %v, expected valid %v", err, tc.valid)
+			}
+		})
+	}
+}
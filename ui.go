@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/bubbles/cursor"
@@ -30,9 +33,13 @@ type fetchMsg struct {
 	success  bool
 	message  string
 	duration time.Duration
-	source   string // "timenet" or "kimai"
+	source   string // name of the TimeSource, e.g. "Timenet"
+	fetch    int    // fetchID of the fetch it belongs to
 }
 
+// a step reported by a running fetch
+type progressMsg fetchProgress
+
 type TimedMessage struct {
 	text      string
 	timestamp time.Time
@@ -41,12 +48,21 @@ type TimedMessage struct {
 
 type clearExpiredMsg struct{}
 
+// result of unlocking the vault or storing credentials in it
+type vaultMsg struct {
+	creds   Credentials
+	message string
+	err     error
+}
+
 type model struct {
 	focusIndex     int
 	inputs         []textinput.Model
 	cursorMode     cursor.Mode
 	loginSubmitted bool
 	showAbout      bool
+	remember       bool // store the typed credentials in the vault on submit
+	hasVault       bool // a vault exists, its passphrase field is shown
 
 	// main UI areas
 	mainContent  string         // holds the main content for data coming from JSON files
@@ -54,28 +70,42 @@ type model struct {
 	maxMessages  int            // maximum number of messages to show
 
 	// fetch tracking
-	pendingFetches map[string]bool // tracks which fetches are still running
+	pendingFetches map[string]bool          // tracks which fetches are still running
+	fetchID        int                      // counts the fetches, results of older ones are dropped
+	cancelFetch    context.CancelFunc       // stops the running fetches
+	fetchProgress  map[string]fetchProgress // last step reported by each running fetch
+	fetchStarted   time.Time
+	progress       chan fetchProgress // steps reported by the running fetches
+	progressDone   chan struct{}      // closed once all fetches are done
 
 	timenetPassword string
 	kimaiID         string
 	kimaiPassword   string
 
-	spinner    spinner.Model
-	isLoading  bool
-	monthIndex int // tracks which month to display (0=current, 1=previous, etc.)
+	spinner     spinner.Model
+	isLoading   bool
+	monthIndex  int  // tracks which month to display (0=current, 1=previous, etc.)
+	showPunches bool // list the Timenet punches below each day
+
+	fetchRange FetchRange // months to fetch when pressing 'f'
 }
 
-func newModel() model {
+func newModel(fetchRange FetchRange) model {
 	s := spinner.New()
 	s.Spinner = spinner.MiniDot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
 
 	m := model{
-		inputs:         make([]textinput.Model, 3),
+		inputs:         make([]textinput.Model, 4),
 		spinner:        s,
 		messageQueue:   make([]TimedMessage, 0),
 		maxMessages:    3,
 		pendingFetches: make(map[string]bool),
+		fetchProgress:  make(map[string]fetchProgress),
+		fetchRange:     fetchRange,
+	}
+	if path, err := vaultPath(); err == nil {
+		m.hasVault = vaultExists(path)
 	}
 
 	var t textinput.Model
@@ -100,6 +130,10 @@ func newModel() model {
 			t.Placeholder = "Kimai Password"
 			t.EchoMode = textinput.EchoPassword
 			t.EchoCharacter = '•'
+		case 3:
+			t.Placeholder = "Vault Passphrase (optional)"
+			t.EchoMode = textinput.EchoPassword
+			t.EchoCharacter = '•'
 		}
 
 		m.inputs[i] = t
@@ -108,6 +142,15 @@ func newModel() model {
 	return m
 }
 
+// returns how many inputs the login form shows, the vault passphrase is
+// only asked when there is a vault or the credentials are to be remembered
+func (m model) inputCount() int {
+	if m.hasVault || m.remember {
+		return len(m.inputs)
+	}
+	return len(m.inputs) - 1
+}
+
 func (m model) Init() tea.Cmd {
 	return textinput.Blink
 }
@@ -167,21 +210,28 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case fetchMsg:
+		// cancelled fetches, or replaced by a newer one, report nothing
+		if msg.fetch != m.fetchID || !m.pendingFetches[msg.source] {
+			slog.Info("Result of a cancelled fetch dropped", "source", msg.source, "message", msg.message)
+			return m, nil
+		}
 		slog.Info("Fetch completed", "source", msg.source, "success", msg.success, "message", msg.message)
 
 		// Remove this fetch from pending
 		delete(m.pendingFetches, msg.source)
+		delete(m.fetchProgress, msg.source)
 
 		cmd := m.addMessage(msg.message, msg.duration)
 
 		// Only trigger summary build if ALL fetches are complete
 		if len(m.pendingFetches) == 0 {
+			m.cancelFetches() // releases the context, nothing left to stop
 			// All fetches done - keep only the last message and load summary
 			if len(m.messageQueue) > 1 {
 				m.messageQueue = m.messageQueue[len(m.messageQueue)-1:]
 			}
 			return m, tea.Batch(cmd, func() tea.Msg {
-				summary := BuildSummary(m.monthIndex)
+				summary := BuildSummary(m.monthIndex, m.showPunches)
 				return mainContentMsg{output: summary}
 			})
 		}
@@ -189,10 +239,31 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Some fetches still pending - just show the message
 		return m, cmd
 
+	case progressMsg:
+		// steps of fetches no longer tracked, e.g. after a logout, are dropped
+		if m.progress == nil {
+			return m, nil
+		}
+		if m.pendingFetches[msg.Source] {
+			m.fetchProgress[msg.Source] = fetchProgress(msg)
+		}
+		return m, waitForProgress(m.progress, m.progressDone)
+
 	case clearExpiredMsg:
 		m.clearExpiredMessages()
 		return m, nil
 
+	case vaultMsg:
+		if msg.err != nil {
+			slog.Warn("Vault not used", "error", msg.err)
+			cmd := m.addMessage("Vault: "+msg.err.Error(), 5*time.Second)
+			return m, cmd
+		}
+		m.hasVault = true
+		m.login(msg.creds)
+		cmd := m.addMessage(msg.message, 3*time.Second)
+		return m, cmd
+
 	case spinner.TickMsg:
 		var cmd tea.Cmd
 		m.spinner, cmd = m.spinner.Update(msg)
@@ -201,15 +272,28 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c", "esc":
+			// Chromium is closed by the fetches, main waits for them
+			m.cancelFetches()
 			return m, tea.Quit
 
+		case "s":
+			// stop the running fetches
+			if m.loginSubmitted && !m.showAbout && len(m.pendingFetches) > 0 {
+				m.cancelFetches()
+				m.isLoading = false
+				slog.Info("Fetch cancelled")
+				m.messageQueue = nil
+				cmd := m.addMessage("Fetch cancelled", 3*time.Second)
+				return m, cmd
+			}
+
 		case "x":
 			// Only handle logout if we're logged in (not typing in inputs)
 			if m.loginSubmitted {
 				m.loginSubmitted = false
 				m.focusIndex = 0
-				m.messageQueue = nil                     // Clear all messages
-				m.pendingFetches = make(map[string]bool) // Clear pending fetches
+				m.messageQueue = nil // Clear all messages
+				m.cancelFetches()    // Stop and clear pending fetches
 				m.isLoading = false
 				// Clear all input values
 				for i := range m.inputs {
@@ -247,7 +331,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.spinner.Tick,
 					cmd,
 					func() tea.Msg {
-						summary := BuildSummary(m.monthIndex)
+						summary := BuildSummary(m.monthIndex, m.showPunches)
 						return mainContentMsg{output: summary}
 					},
 				)
@@ -255,12 +339,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "left":
 			if m.loginSubmitted && !m.showAbout {
 
-				// simple hack to prevent going past available months in the current year
-				// this number should depend on the data available in the timenet JSON file
-				// for simplicity, we just calculate based on the current month
-				monthsAvailable := int(time.Now().Month()) - 1
+				// prevent going past the months available in the timenet JSON file
+				monthsAvailable := availableMonths() - 1
 
-				if m.monthIndex < monthsAvailable { // 0-11 for 12 months
+				if m.monthIndex < monthsAvailable {
 					m.monthIndex++
 				}
 				m.isLoading = true
@@ -269,7 +351,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.spinner.Tick,
 					cmd,
 					func() tea.Msg {
-						summary := BuildSummary(m.monthIndex)
+						summary := BuildSummary(m.monthIndex, m.showPunches)
 						return mainContentMsg{output: summary}
 					},
 				)
@@ -285,49 +367,105 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.spinner.Tick,
 					cmd,
 					func() tea.Msg {
-						summary := BuildSummary(m.monthIndex)
+						summary := BuildSummary(m.monthIndex, m.showPunches)
 						return mainContentMsg{output: summary}
 					},
 				)
 			}
 
-		case "f":
+		case "[", "]", "{", "}":
+			// move the start ([ ]) or the end ({ }) of the fetch range by one month
 			if m.loginSubmitted && !m.showAbout {
-				if m.timenetPassword == "" {
-					cmd := m.addMessage("Timenet password is blank, use valid password", 5*time.Second)
+				r := m.fetchRange
+				switch msg.String() {
+				case "[":
+					r.From = r.From.AddDate(0, -1, 0)
+				case "]":
+					r.From = r.From.AddDate(0, 1, 0)
+				case "{":
+					r.To = r.To.AddDate(0, -1, 0)
+				case "}":
+					r.To = r.To.AddDate(0, 1, 0)
+				}
+				if err := r.validate(); err != nil {
+					cmd := m.addMessage("Fetch range not changed: "+err.Error(), 3*time.Second)
 					return m, cmd
 				}
-				if m.kimaiID == "" || m.kimaiPassword == "" {
-					cmd := m.addMessage("Kimai credentials are blank, use valid credentials", 5*time.Second)
+				m.fetchRange = r
+				cmd := m.addMessage(fmt.Sprintf("Fetch range: %s (%d months)", r, r.months()), 3*time.Second)
+				return m, cmd
+			}
+
+		case "f":
+			if m.loginSubmitted && !m.showAbout {
+				sources, err := newSources(m.credentials())
+				if err != nil {
+					cmd := m.addMessage(err.Error(), 5*time.Second)
 					return m, cmd
 				}
 
-				// Initialize fetch tracking
-				m.pendingFetches = map[string]bool{
-					"timenet": true,
-					"kimai":   true,
+				// Initialize fetch tracking, a fetch still running is replaced
+				m.cancelFetches()
+				m.fetchID++
+				for _, src := range sources {
+					m.pendingFetches[src.Name()] = true
+					m.fetchProgress[src.Name()] = fetchProgress{Source: src.Name(), Months: m.fetchRange.months()}
 				}
+				m.fetchStarted = time.Now()
 				m.isLoading = true
 
-				cmd := m.addMessage("Fetching remote data...", 60*time.Second)
+				// steps are dropped rather than slowing down the scrapers when
+				// the UI is behind, the next one replaces them anyway
+				progress := make(chan fetchProgress, 64)
+				m.progress, m.progressDone = progress, make(chan struct{})
+				ctx, cancel := context.WithCancel(context.Background())
+				m.cancelFetch = cancel
+				ctx = withProgress(ctx, func(p fetchProgress) {
+					select {
+					case progress <- p:
+					default:
+					}
+				})
+
+				cmd := m.addMessage(fmt.Sprintf("Fetching remote data for %s...", m.fetchRange), 60*time.Second)
 				slog.Info("Fetching remote data...")
 
+				cmds := []tea.Cmd{m.spinner.Tick, cmd, waitForProgress(m.progress, m.progressDone)}
+				for _, src := range sources {
+					cmds = append(cmds, fetchCmd(ctx, m.fetchID, src, m.fetchRange))
+				}
+				return m, tea.Batch(cmds...)
+			}
+
+		case "p":
+			// show or hide the Timenet punches of each day
+			if m.loginSubmitted && !m.showAbout {
+				m.showPunches = !m.showPunches
+				m.isLoading = true
+				message := "Hiding punches"
+				if m.showPunches {
+					message = "Showing punches below each day"
+				}
+				cmd := m.addMessage(message, 2*time.Second)
 				return m, tea.Batch(
 					m.spinner.Tick,
 					cmd,
 					func() tea.Msg {
-						err := fetchTimenet(m.timenetPassword)
-						if err != nil {
-							return fetchMsg{success: false, message: "Timenet fetch failed: " + err.Error(), duration: 5 * time.Second, source: "timenet"}
-						}
-						return fetchMsg{success: true, message: "Timenet fetch completed successfully", duration: 5 * time.Second, source: "timenet"}
+						return mainContentMsg{output: BuildSummary(m.monthIndex, m.showPunches)}
 					},
+				)
+			}
+
+		case "d":
+			// show what changed since the previous fetch
+			if m.loginSubmitted && !m.showAbout {
+				m.isLoading = true
+				cmd := m.addMessage("Loaded changes since the previous fetch", 3*time.Second)
+				return m, tea.Batch(
+					m.spinner.Tick,
+					cmd,
 					func() tea.Msg {
-						err := fetchKimai(m.kimaiID, m.kimaiPassword)
-						if err != nil {
-							return fetchMsg{success: false, message: "Kimai fetch failed: " + err.Error(), duration: 5 * time.Second, source: "kimai"}
-						}
-						return fetchMsg{success: true, message: "Kimai fetch completed successfully", duration: 5 * time.Second, source: "kimai"}
+						return mainContentMsg{output: BuildDiff()}
 					},
 				)
 			}
@@ -339,6 +477,26 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
+		case "ctrl+s":
+			// toggle remember me in the login form
+			if !m.loginSubmitted {
+				m.remember = !m.remember
+				if m.inputCount() < len(m.inputs) {
+					m.inputs[len(m.inputs)-1].SetValue("")
+				}
+				// the passphrase field is hidden again, move back to the last shown one
+				if m.focusIndex >= m.inputCount() {
+					m.focusIndex = m.inputCount() - 1
+					m.inputs[len(m.inputs)-1].Blur()
+					m.inputs[len(m.inputs)-1].PromptStyle = noStyle
+					m.inputs[len(m.inputs)-1].TextStyle = noStyle
+					m.inputs[m.focusIndex].PromptStyle = focusedStyle
+					m.inputs[m.focusIndex].TextStyle = focusedStyle
+					return m, m.inputs[m.focusIndex].Focus()
+				}
+				return m, nil
+			}
+
 		case "ctrl+r":
 			m.cursorMode++
 			if m.cursorMode > cursor.CursorHide {
@@ -355,12 +513,25 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			s := msg.String()
 
 			// Submit when Enter is pressed on the last field
-			if s == "enter" && m.focusIndex == len(m.inputs)-1 {
-				// Store credentials
-				m.timenetPassword = m.inputs[0].Value()
-				m.kimaiID = m.inputs[1].Value()
-				m.kimaiPassword = m.inputs[2].Value()
-				m.loginSubmitted = true
+			if s == "enter" && m.focusIndex == m.inputCount()-1 {
+				creds := Credentials{
+					TimenetPassword: m.inputs[0].Value(),
+					KimaiID:         m.inputs[1].Value(),
+					KimaiPassword:   m.inputs[2].Value(),
+				}
+				passphrase := m.inputs[3].Value()
+				m.inputs[3].SetValue("")
+
+				// with a passphrase, blank credentials are read from the vault
+				// and typed ones are stored in it when remember me is on
+				if passphrase != "" && (creds == Credentials{} || m.remember) {
+					return m, vaultCmd(passphrase, creds)
+				}
+				if m.remember {
+					cmd := m.addMessage("Vault: type a passphrase to remember your credentials", 5*time.Second)
+					return m, cmd
+				}
+				m.login(creds)
 				return m, nil // Don't quit, just change state
 			}
 
@@ -371,10 +542,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.focusIndex++
 			}
 
-			if m.focusIndex > len(m.inputs)-1 {
+			if m.focusIndex > m.inputCount()-1 {
 				m.focusIndex = 0
 			} else if m.focusIndex < 0 {
-				m.focusIndex = len(m.inputs) - 1
+				m.focusIndex = m.inputCount() - 1
 			}
 
 			cmds := make([]tea.Cmd, len(m.inputs))
@@ -405,6 +576,160 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// stores the credentials and leaves the login form
+func (m *model) login(creds Credentials) {
+	m.timenetPassword = creds.TimenetPassword
+	m.kimaiID = creds.KimaiID
+	m.kimaiPassword = creds.KimaiPassword
+	m.loginSubmitted = true
+}
+
+// unlocks the vault, or stores the credentials in it, in background
+func vaultCmd(passphrase string, creds Credentials) tea.Cmd {
+	return func() tea.Msg {
+		if creds == (Credentials{}) {
+			creds, err := unlockProfile(passphrase, vaultProfile)
+			return vaultMsg{creds: creds, message: fmt.Sprintf("Vault: profile %s unlocked", vaultProfile), err: err}
+		}
+		err := rememberProfile(passphrase, vaultProfile, creds)
+		return vaultMsg{creds: creds, message: fmt.Sprintf("Vault: profile %s saved", vaultProfile), err: err}
+	}
+}
+
+// returns the credentials typed in the login form
+func (m *model) credentials() Credentials {
+	return Credentials{
+		TimenetPassword: m.timenetPassword,
+		KimaiID:         m.kimaiID,
+		KimaiPassword:   m.kimaiPassword,
+	}
+}
+
+// fetches started from the UI, main waits for them on exit so that each one
+// closes its Chromium. A fetch is only counted once its command runs, a
+// command run after waiting started does not fetch at all.
+var (
+	runningFetches sync.WaitGroup
+	fetchesMu      sync.Mutex
+	fetchesClosed  bool
+)
+
+// counts a fetch about to run, false once main is waiting on exit
+func startFetch() bool {
+	fetchesMu.Lock()
+	defer fetchesMu.Unlock()
+	if fetchesClosed {
+		return false
+	}
+	runningFetches.Add(1)
+	return true
+}
+
+// waits for the fetches still running after a cancel, at most timeout
+func waitForFetches(timeout time.Duration) bool {
+	fetchesMu.Lock()
+	fetchesClosed = true
+	fetchesMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		runningFetches.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// runs the fetch of one source in background and reports back with a fetchMsg
+func fetchCmd(ctx context.Context, fetch int, src TimeSource, r FetchRange) tea.Cmd {
+	return func() tea.Msg {
+		msg := fetchMsg{success: true, duration: 5 * time.Second, source: src.Name(), fetch: fetch}
+		if !startFetch() {
+			msg.success, msg.message = false, src.Name()+" fetch cancelled"
+			return msg
+		}
+		defer runningFetches.Done()
+
+		months, diags, err := fetchSource(ctx, src, r)
+		var partial *partialFetchError
+		switch {
+		case ctx.Err() != nil:
+			msg.success, msg.message = false, src.Name()+" fetch cancelled"
+		case errors.As(err, &partial):
+			// the months fetched are stored, shown like a success
+			msg.message, msg.duration = fmt.Sprintf("%s: %v", src.Name(), partial), 8*time.Second
+		case err != nil:
+			msg.success, msg.message = false, src.Name()+" fetch failed: "+err.Error()
+		case months == 0:
+			msg.message = src.Name() + " is already up to date"
+		case len(diags) > 0:
+			// stored, but worth a look
+			msg.message, msg.duration = fmt.Sprintf("%s fetched %d months with %s", src.Name(), months, diags.summary()), 8*time.Second
+		default:
+			msg.message = fmt.Sprintf("%s fetch completed successfully (%d months)", src.Name(), months)
+		}
+		return msg
+	}
+}
+
+// waits for the next step reported by the running fetches, until they are done
+func waitForProgress(progress <-chan fetchProgress, done <-chan struct{}) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case p := <-progress:
+			// a step read as the fetches were cancelled is dropped
+			select {
+			case <-done:
+				return nil
+			default:
+			}
+			return progressMsg(p)
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// cancels the running fetches, each one closes its Chromium, and forgets them
+func (m *model) cancelFetches() {
+	if m.cancelFetch != nil {
+		m.cancelFetch()
+		m.cancelFetch = nil
+	}
+	m.pendingFetches = make(map[string]bool)
+	m.stopProgress()
+}
+
+// stops waiting for fetch steps and hides the progress bars
+func (m *model) stopProgress() {
+	if m.progressDone != nil {
+		close(m.progressDone)
+	}
+	m.progress, m.progressDone = nil, nil
+	m.fetchProgress = make(map[string]fetchProgress)
+}
+
+// returns one progress bar per running fetch, in the order of the sources,
+// and the time since the fetch started
+func (m model) progressView() string {
+	var b strings.Builder
+	for _, r := range sourceRegistry {
+		p, found := m.fetchProgress[r.name]
+		if !found {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("%-8s %s %3.0f%% %s\n", r.name, progressBar(p.done(), 30), p.done()*100, statusMessageStyle.Render(p.String())))
+	}
+	if b.Len() > 0 {
+		b.WriteString(statusMessageStyle.Render(fmt.Sprintf("elapsed %s", time.Since(m.fetchStarted).Round(time.Second))) + "\n")
+	}
+	return b.String()
+}
+
 func (m *model) updateInputs(msg tea.Msg) tea.Cmd {
 	cmds := make([]tea.Cmd, len(m.inputs))
 	for i := range m.inputs {
@@ -430,6 +755,8 @@ func (m model) View() string {
 			b.WriteString(BuildSplashScreen())
 		}
 
+		b.WriteString(m.progressView())
+
 		// Show status message with spinner if loading
 		currentMsg := m.getCurrentMessage()
 		if m.isLoading && currentMsg != "" {
@@ -440,17 +767,25 @@ func (m model) View() string {
 			b.WriteString("\n") // leaves a blank line when there is no status message
 		}
 
-		b.WriteString(helpStyle.Render("f fetch • l load • ← → prev/next • c clear • x logout • a about"))
+		b.WriteString(helpStyle.Render("f fetch • s stop • [ ] { } range • l load • p punches • d diff • ← → prev/next • c clear • x logout • a about"))
 
 	} else {
 		// Show the input form
-		for i := range m.inputs {
+		for i := 0; i < m.inputCount(); i++ {
 			b.WriteString(m.inputs[i].View())
-			if i < len(m.inputs)-1 {
+			if i < m.inputCount()-1 {
 				b.WriteRune('\n')
 			}
 		}
-		b.WriteString(helpStyle.Render("\n\nenter submit • esc leave"))
+		b.WriteString("\n\n")
+		if currentMsg := m.getCurrentMessage(); currentMsg != "" {
+			b.WriteString(statusMessageStyle.Render(currentMsg))
+		}
+		remember := "[ ]"
+		if m.remember {
+			remember = "[x]"
+		}
+		b.WriteString(helpStyle.Render(fmt.Sprintf("\nenter submit • ctrl+s remember me %s • esc leave", remember)))
 
 	}
 
@@ -1,16 +1,12 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"log/slog"
-	"math"
-	"os"
-	"path/filepath"
-	"sort"
 	"strings"
 	"time"
 
+	"timo/reconcile"
+
 	"github.com/charmbracelet/lipgloss"
 )
 
@@ -19,165 +15,123 @@ var yellowStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("226"))
 var reverseStyle = lipgloss.NewStyle().Reverse(true)
 var italicStyle = lipgloss.NewStyle().Italic(true)
 
-// readLatestJSON finds and reads the most recent JSON file with the given prefix
-func readLatestJSON[T any](prefix string) (*T, error) {
-	tempDir := os.TempDir()
-	pattern := filepath.Join(tempDir, prefix+"*.json")
-	matches, err := filepath.Glob(pattern)
+// returns how many Timenet months are available in the history
+func availableMonths() int {
+	data, err := loadAttendance()
 	if err != nil {
-		return nil, fmt.Errorf("failed to search for JSON files: %v", err)
-	}
-	if len(matches) == 0 {
-		return nil, fmt.Errorf("no JSON files found in %s with prefix %s", tempDir, prefix)
+		return 0
 	}
-	sort.Slice(matches, func(i, j int) bool {
-		infoI, errI := os.Stat(matches[i])
-		infoJ, errJ := os.Stat(matches[j])
-		if errI != nil || errJ != nil {
-			return false
-		}
-		return infoI.ModTime().After(infoJ.ModTime())
-	})
-	latestFile := matches[0]
-	slog.Info("Loading latest JSON file", "filename", latestFile)
-	jsonData, err := os.ReadFile(latestFile)
+	return len(data.MonthlyData)
+}
+
+// returns a summary string combining Timenet and Kimai data from the history
+// to be directed to the main content area of the UI, with the punches of
+// every day below it when showPunches is set
+func BuildSummary(whatMonth int, showPunches bool) string {
+
+	timenet_data, err := loadAttendance()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s: %v", latestFile, err)
+		return ""
 	}
-	var data T
-	err = json.Unmarshal(jsonData, &data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse JSON from %s: %v", latestFile, err)
+
+	// limit month navigation to what is available in the history
+	monthCount := len(timenet_data.MonthlyData)
+	if monthCount == 0 {
+		return ""
 	}
-	return &data, nil
-}
+	whatMonth = max(0, min(whatMonth, monthCount-1))
 
-// returns a summary string combining data from both Timenet and Kimai JSON files
-// to be directed to the main content area of the UI
-func BuildSummary(whatMonth int) string {
+	return buildMonthSummary(timenet_data, whatMonth, showPunches)
+}
 
-	timenet_data, err := readLatestJSON[TimenetData]("timenet_data_")
+// returns the summary string of one month, given as YYYY-MM
+func BuildMonthReport(monthKey string, showPunches bool) (string, error) {
+	timenet_data, err := loadAttendance()
 	if err != nil {
-		return ""
+		return "", err
 	}
+	whatMonth := monthIndexOf(timenet_data, monthKey)
+	if whatMonth < 0 {
+		return "", fmt.Errorf("month %s not found in history, fetch it first", monthKey)
+	}
+	return buildMonthSummary(timenet_data, whatMonth, showPunches), nil
+}
 
-	kimai_data, err := readLatestJSON[KimaiData]("kimai_data_")
+// returns the reconciliation of one month, given as YYYY-MM
+func BuildMonthResult(monthKey string) (reconcile.MonthResult, error) {
+	timenet_data, err := loadAttendance()
 	if err != nil {
-		return ""
+		return reconcile.MonthResult{}, err
+	}
+	whatMonth := monthIndexOf(timenet_data, monthKey)
+	if whatMonth < 0 {
+		return reconcile.MonthResult{}, fmt.Errorf("month %s not found in history, fetch it first", monthKey)
 	}
+	return reconcile.Reconcile(reconcileMonth(timenet_data.MonthlyData[whatMonth]), reconcileEntries(loadTimesheetEntries(monthKey)), reconcileRules), nil
+}
 
-	// limit month navigation to what is available in the timenet JSON file
-	monthCount := len(timenet_data.MonthlyData)
-	whatMonth = max(0, min(whatMonth, monthCount-1))
+func buildMonthSummary(timenet_data *TimenetData, whatMonth int, showPunches bool) string {
+
+	// entries of that month from all timesheet sources are added up together
+	kimai_data := &KimaiData{
+		MonthlyData: loadTimesheetEntries(timenet_data.MonthlyData[whatMonth].Month),
+	}
 
 	var result strings.Builder
 
+	monthName := timenet_data.MonthlyData[whatMonth].Month
+	if t, err := time.Parse("2006-01", monthName); err == nil {
+		monthName = t.Format("January 2006")
+	}
 	result.WriteString(fmt.Sprintf("%-18s%37s\n",
-		fmt.Sprintf("%s %s %s", "📅", timenet_data.MonthlyData[whatMonth].Month, timenet_data.Year),
-		fmt.Sprintf("🔬 %s %s", timenet_data.FetchTime, timenet_data.FetchDate)))
+		fmt.Sprintf("%s %s", "📅", monthName),
+		fmt.Sprintf("🔬 %s", timenet_data.FetchedAt.Format("15:04 2006/01/02"))))
 
 	result.WriteString(fmt.Sprintf("%-18s%13s\n\n",
 		fmt.Sprintf("%s %s of %s",
 			"🚧",
-			timenet_data.MonthlyData[whatMonth].WorkedTimeInMonth,
-			timenet_data.MonthlyData[whatMonth].ExpectedWorkedTimeInMonth),
-		fmt.Sprintf("%s %s", "☢️", timenet_data.OvertimeInYear)))
+			formatMinutes(timenet_data.MonthlyData[whatMonth].WorkedMinutes, false),
+			formatMinutes(timenet_data.MonthlyData[whatMonth].ExpectedMinutes, false)),
+		fmt.Sprintf("%s %s", "☢️", formatMinutes(timenet_data.OvertimeMinutesInYear, false))))
+
+	month := reconcile.Reconcile(reconcileMonth(timenet_data.MonthlyData[whatMonth]), reconcileEntries(kimai_data.MonthlyData), reconcileRules)
 
 	// lets plot here a table with daily data
 	result.WriteString(" Date          | Overtime | Timenet | Kimai   | Diff  \n")
 	result.WriteString("---------------------------------------------------------\n")
 
-	var monthly_diff int = 0
-	var monthly_overtime int = 0
-	var monthly_timenet int = 0
-	var monthly_kimai int = 0
-
-	for _, day := range timenet_data.MonthlyData[whatMonth].DailyData {
-
-		var kimai_worked_time int = 0
-
-		var dayType string
-		switch {
-		case day.IsHoliday:
-			dayType = "🎉"
-		case day.IsVacation:
-			dayType = "🏖️" //🏖️ 🏝️
-		case day.IsMedicalLeave:
-			dayType = "🩺" // 🚑
-		case day.IsCalendarAdjustment:
-			dayType = "📅"
-		case day.IsWeekend:
-			dayType = "💤"
-		case day.IsWorkDay:
-			dayType = "🚧" //🧑‍💼🔨🔧💼🧰💰🧪🚧🪚
-		default:
-			dayType = "💩" //💃🌙😎⛺🏡
-		}
-
-		// search for timenet_data.MonthlyData.Date inside kimai_data.MonthlyData.Date and
-		// if the data match, pick kimai_data.MonthlyData.WorkedTime and add them up
-		for _, kimaiDay := range kimai_data.MonthlyData {
-			if kimaiDay.Date == day.Date {
-
-				kimai_minutes, err := convertTimeStringToMinutes(kimaiDay.WorkedTime)
-
-				// there might be more than one entry for the same day, so we sum them up
-				if err == nil {
-					// we add up only work time, therefore only time  for days where:
-					// "project" is not "Break" or
-					// Activity does not contain "vacation" or
-					// Activity does not contain "holiday" or
-					// Activity does not contain "free time"
-					if strings.ToLower(kimaiDay.Project) != "break" &&
-						!strings.Contains(strings.ToLower(kimaiDay.Activity), "vacation") &&
-						!strings.Contains(strings.ToLower(kimaiDay.Activity), "holiday") &&
-						!strings.Contains(strings.ToLower(kimaiDay.Activity), "free time") {
-						kimai_worked_time += kimai_minutes
-					} else {
-						slog.Info("Skipping Break entry from Kimai data for date " + kimaiDay.Date)
-					}
-				}
-			}
-		}
-
-		// accumulate overtime over the month
-		overtime, err := convertTimeStringToMinutes(day.OvertimeInDay)
-		if err == nil {
-			monthly_overtime += overtime
-		}
-
-		// Add to timenet total
-		timenet_worked_time, err := convertTimeStringToMinutes(day.WorkedTimeInDay)
-		if err == nil {
-			monthly_timenet += timenet_worked_time
-		}
-
-		// accumulate kimai worked time
-		monthly_kimai += kimai_worked_time
-
-		// Calculate diff and accumulate to monthly_diff
-		daily_diff := kimai_worked_time - timenet_worked_time
-		monthly_diff += daily_diff
+	for _, day := range month.Days {
 
 		// add warning icon if absolute difference is > 59min
 		warning := " "
-		if math.Abs(float64(daily_diff)) > 59 {
+		if day.HasFlag(reconcile.FlagLargeDiff) {
 			warning = yellowStyle.Render("⚡")
 		}
-
-		// TODO is this correct when it is a Flexitime day?
-		kimaiWorkedTime := strings.TrimPrefix(convertMinutesToTimeString(kimai_worked_time), "+")
-		diff := convertMinutesToTimeString(daily_diff)
+		if day.HasFlag(reconcile.FlagMissingClockOut) {
+			warning += redStyle.Render("⏰")
+		}
 
 		currentDate := day.Date
-		if day.Date == time.Now().Format("2006/01/02") {
+		if day.Date == time.Now().Format("2006-01-02") {
 			currentDate = reverseStyle.Render(day.Date)
 		}
 
 		result.WriteString(fmt.Sprintf(" %-10s %s | %-8s | %-7s | %-7s | %-7s %s\n",
-			currentDate, dayType, day.OvertimeInDay, day.WorkedTimeInDay, kimaiWorkedTime, diff, warning,
+			currentDate, dayTypeIcon(DayType(day.DayType)),
+			formatDayMinutes(day.Overtime, true),
+			formatDayMinutes(day.Worked, false),
+			formatMinutes(day.Kimai, false),
+			convertMinutesToTimeString(day.Diff), warning,
 		))
 
+		if showPunches && len(day.Punches) > 0 {
+			line := fmt.Sprintf("%14s🕘 %s = %s", "", formatPunches(day.Punches), formatMinutes(punchedMinutes(day.Punches), false))
+			if day.HasFlag(reconcile.FlagMissingClockOut) {
+				line += " missing clock-out"
+			}
+			result.WriteString(italicStyle.Render(line) + "\n")
+		}
+
 	}
 	result.WriteString("---------------------------------------------------------\n")
 
@@ -185,15 +139,120 @@ func BuildSummary(whatMonth int) string {
 	result.WriteString(
 		fmt.Sprintf(" %-10s %s   %-10s %-9s %-9s %-9s\n",
 			"", "🎲",
-			convertMinutesToTimeString(monthly_overtime),
-			strings.TrimPrefix(convertMinutesToTimeString(monthly_timenet), "+"),
-			strings.TrimPrefix(convertMinutesToTimeString(monthly_kimai), "+"),
-			redStyle.Render(convertMinutesToTimeString(monthly_diff)),
+			convertMinutesToTimeString(month.Overtime),
+			formatMinutes(month.Worked, false),
+			formatMinutes(month.Kimai, false),
+			redStyle.Render(convertMinutesToTimeString(month.Diff)),
 		))
 
+	// time booked in Kimai that is not work
+	if month.Break != 0 || month.Absence != 0 {
+		result.WriteString(italicStyle.Render(fmt.Sprintf(" not counted: ☕ %s break • 🏖️ %s absence",
+			formatMinutes(month.Break, false),
+			formatMinutes(month.Absence, false))) + "\n")
+	}
+
+	return result.String()
+}
+
+// returns what changed between the last two fetches of every source
+func BuildDiff() string {
+	var result strings.Builder
+
+	result.WriteString("🔍 Changes since the previous fetch\n\n")
+
+	for _, r := range sourceRegistry {
+		diff, err := diffLastFetches(r.name)
+		if err != nil {
+			result.WriteString(fmt.Sprintf("%s: %s\n\n", r.name, err))
+			continue
+		}
+		if diff.OldFetch.IsZero() {
+			result.WriteString(fmt.Sprintf("%s: fetch twice to see what changed\n\n", r.name))
+			continue
+		}
+
+		result.WriteString(fmt.Sprintf("%s: %s → %s\n",
+			r.name, diff.OldFetch.Format("2006/01/02 15:04"), diff.NewFetch.Format("2006/01/02 15:04")))
+		if len(diff.Changes) == 0 {
+			result.WriteString(italicStyle.Render(" no changes") + "\n\n")
+			continue
+		}
+
+		for _, change := range diff.Changes {
+			sign := "~"
+			switch change.Kind {
+			case ChangeAdded:
+				sign = "+"
+			case ChangeRemoved:
+				sign = redStyle.Render("-")
+			}
+			result.WriteString(fmt.Sprintf(" %s %s  %-40s %s\n",
+				sign, change.Date, change.What, yellowStyle.Render(convertMinutesToTimeString(change.Delta))))
+		}
+		result.WriteString("\n")
+	}
+
 	return result.String()
 }
 
+// returns the icon shown for a day type
+func dayTypeIcon(dayType DayType) string {
+	switch dayType {
+	case DayHoliday:
+		return "🎉"
+	case DayVacation:
+		return "🏖️" //🏖️ 🏝️
+	case DayMedicalLeave:
+		return "🩺" // 🚑
+	case DayPersonalLeave:
+		return "🧳"
+	case DayTraining:
+		return "🎓"
+	case DayRemoteWork:
+		return "🏡"
+	case DayCalendarAdjustment:
+		return "📅"
+	case DayWeekend:
+		return "💤"
+	case DayWork:
+		return "🚧" //🧑‍💼🔨🔧💼🧰💰🧪🚧🪚
+	}
+	return "💩" //💃🌙😎⛺
+}
+
+// formats minutes for display like "9h 14m", signed values get a "+" when positive
+func formatMinutes(minutes int, signed bool) string {
+	if signed {
+		return convertMinutesToTimeString(minutes)
+	}
+	if minutes == 0 {
+		return "0m"
+	}
+	return strings.TrimPrefix(convertMinutesToTimeString(minutes), "+")
+}
+
+// formats punches like "08:58-13:02 13:45-?", ? when the clock-out is missing
+func formatPunches(punches []Punch) string {
+	var pairs []string
+	for _, p := range punches {
+		out := p.Out
+		if out == "" {
+			out = "?"
+		}
+		pairs = append(pairs, p.In+"-"+out)
+	}
+	return strings.Join(pairs, " ")
+}
+
+// formats minutes for a table cell, blank when there is nothing to show
+func formatDayMinutes(minutes int, signed bool) string {
+	if minutes == 0 {
+		return ""
+	}
+	return formatMinutes(minutes, signed)
+}
+
 func BuildAboutMessage() string {
 
 	var result strings.Builder
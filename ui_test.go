@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestCancelFetch stops a running fetch with the s key and drops its result
+func TestCancelFetch(t *testing.T) {
+	m := newModel(defaultFetchRange())
+	m.loginSubmitted = true
+	m.fetchID = 1
+	m.pendingFetches["Timenet"] = true
+	m.pendingFetches["Kimai"] = true
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelFetch = cancel
+	done := make(chan struct{})
+	m.progress, m.progressDone = make(chan fetchProgress), done
+	m.fetchProgress["Timenet"] = fetchProgress{Source: "Timenet", Stage: stageLoggedIn}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	stopped := updated.(model)
+	if ctx.Err() != context.Canceled {
+		t.Errorf("got context error %v, expected %v", ctx.Err(), context.Canceled)
+	}
+	if len(stopped.pendingFetches) != 0 {
+		t.Errorf("got %d pending fetches, expected none", len(stopped.pendingFetches))
+	}
+	if view := stopped.progressView(); view != "" {
+		t.Errorf("got progress bars '%s', expected none", view)
+	}
+	if stopped.isLoading {
+		t.Error("still loading after cancel")
+	}
+	if msg := stopped.getCurrentMessage(); msg != "Fetch cancelled" {
+		t.Errorf("got message '%s', expected 'Fetch cancelled'", msg)
+	}
+
+	updated, cmd := stopped.Update(fetchMsg{success: false, message: "Timenet fetch cancelled", source: "Timenet", fetch: 1})
+	dropped := updated.(model)
+	if msg := dropped.getCurrentMessage(); msg != "Fetch cancelled" {
+		t.Errorf("cancelled result not dropped, got message '%s'", msg)
+	}
+	if cmd != nil {
+		t.Error("summary reloaded after a cancelled fetch")
+	}
+
+	// a fetch started before is dropped once a new one runs
+	dropped.fetchID = 2
+	dropped.pendingFetches["Timenet"] = true
+	updated, _ = dropped.Update(fetchMsg{success: true, message: "Timenet fetch completed successfully", source: "Timenet", fetch: 1})
+	if !updated.(model).pendingFetches["Timenet"] {
+		t.Error("result of an older fetch ended the running one")
+	}
+
+	if msg := waitForProgress(make(chan fetchProgress), done)(); msg != nil {
+		t.Errorf("got %v, expected waiting to stop when done", msg)
+	}
+}
+
+// TestWaitForFetches quits with a fetch command that never ran, which must
+// not keep main waiting nor start the fetch afterwards
+func TestWaitForFetches(t *testing.T) {
+	t.Cleanup(func() { fetchesClosed = false })
+
+	cmd := fetchCmd(context.Background(), 1, newKimaiAPISource("http://127.0.0.1:0", "jdoe", "secret"), defaultFetchRange())
+	waited := waitForFetches(time.Second)
+	msg, _ := cmd().(fetchMsg)
+
+	if !waited {
+		t.Error("waited for a fetch not started")
+	}
+	if msg.success {
+		t.Error("fetch started after quit")
+	}
+	if msg.message != "Kimai fetch cancelled" {
+		t.Errorf("got message '%s', expected 'Kimai fetch cancelled'", msg.message)
+	}
+}
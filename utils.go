@@ -60,6 +60,15 @@ func convertTimeStringToMinutes(timeStr string) (int, error) {
 	return totalMinutes, nil
 }
 
+// returns the minutes of a time string like "9h 14m", 0 when blank or invalid
+func minutesOf(timeStr string) int {
+	minutes, err := convertTimeStringToMinutes(timeStr)
+	if err != nil {
+		return 0
+	}
+	return minutes
+}
+
 // convertMinutesToTimeString converts total minutes to a time string like "1h 13m" or "-2h 30m"
 func convertMinutesToTimeString(totalMinutes int) string {
 	if totalMinutes == 0 {
@@ -145,7 +154,7 @@ func formatTimeFromHMS(timeStr string) string {
 	return result
 }
 
-// converts date from DD/MM/YYYY format to YYYY/MM/DD format
+// converts date from DD/MM/YYYY format to ISO format YYYY-MM-DD
 func convertDateFormat(dateStr string) string {
 	if dateStr == "" {
 		return ""
@@ -161,7 +170,7 @@ func convertDateFormat(dateStr string) string {
 		slog.Warn("Failed to parse Kimai date", "date", dateStr, "error", err)
 		return dateStr
 	}
-	return parsedTime.Format("2006/01/02")
+	return parsedTime.Format("2006-01-02")
 }
 
 // testConvertTimeStringToMinutes tests the convertTimeStringToMinutes function with various inputs
@@ -343,19 +352,19 @@ func testConvertDateFormat() {
 		expected string
 	}{
 		// Valid date formats
-		{"01/01/2025", "2025/01/01"},
-		{"25/12/2024", "2024/12/25"},
-		{"15/06/2023", "2023/06/15"},
-		{"29/02/2024", "2024/02/29"}, // Leap year
-		{"31/12/1999", "1999/12/31"},
+		{"01/01/2025", "2025-01-01"},
+		{"25/12/2024", "2024-12-25"},
+		{"15/06/2023", "2023-06-15"},
+		{"29/02/2024", "2024-02-29"}, // Leap year
+		{"31/12/1999", "1999-12-31"},
 
 		// Edge cases with spaces
 		{"", ""},
 		{" ", ""},                        // Only spaces should return empty
-		{"  01/01/2025  ", "2025/01/01"}, // Should trim and convert
-		{" 01/01/2025", "2025/01/01"},    // Should trim and convert
-		{"01/01/2025 ", "2025/01/01"},    // Should trim and convert
-		{"  25/12/2024  ", "2024/12/25"}, // Should trim and convert
+		{"  01/01/2025  ", "2025-01-01"}, // Should trim and convert
+		{" 01/01/2025", "2025-01-01"},    // Should trim and convert
+		{"01/01/2025 ", "2025-01-01"},    // Should trim and convert
+		{"  25/12/2024  ", "2024-12-25"}, // Should trim and convert
 
 		// Invalid formats (should return original)
 		{"invalid", "invalid"},
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// name of the vault profile used when none is given with --profile
+var vaultProfile string = "default"
+
+// vault keeps the credentials of one or more profiles in a file encrypted
+// with XChaCha20-Poly1305. The key is derived from a master passphrase with
+// Argon2id. The header is stored in clear and authenticated with the data.
+type vault struct {
+	path     string
+	key      []byte
+	header   vaultHeader
+	Profiles map[string]Credentials `json:"profiles"`
+}
+
+type vaultHeader struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"` // KiB
+	Threads uint8  `json:"threads"`
+}
+
+// content of the vault file
+type vaultFile struct {
+	Header vaultHeader `json:"header"`
+	Nonce  []byte      `json:"nonce"`
+	Data   []byte      `json:"data"`
+}
+
+// returns the path of the vault, ~/.config/timo/vault.json in Linux
+func vaultPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "timo", "vault.json"), nil
+}
+
+// returns true if the vault file exists, the vault is opt-in
+func vaultExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// highest Argon2id parameters accepted from a file header, so a tampered
+// header cannot make timo spend minutes or gigabytes deriving a key
+const (
+	maxKDFTime    = 10
+	maxKDFMemory  = 1024 * 1024 // KiB
+	maxKDFThreads = 16
+)
+
+// returns the header of a new file, with a random salt and the given passes
+func newVaultHeader(passes uint32) (vaultHeader, error) {
+	header := vaultHeader{
+		Version: 1,
+		KDF:     "argon2id",
+		Salt:    make([]byte, 16),
+		Time:    passes,
+		Memory:  64 * 1024,
+		Threads: 4,
+	}
+	_, err := rand.Read(header.Salt)
+	return header, err
+}
+
+func (h vaultHeader) deriveKey(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), h.Salt, h.Time, h.Memory, h.Threads, chacha20poly1305.KeySize)
+}
+
+// reads an encrypted file, vault or session as told by kind, and checks its
+// header before any key is derived from it
+func readVaultFile(path string, kind string) (vaultFile, error) {
+	var file vaultFile
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return file, err
+	}
+	if err := json.Unmarshal(content, &file); err != nil {
+		return file, fmt.Errorf("invalid %s file %s: %v", kind, path, err)
+	}
+	h := file.Header
+	if h.Version != 1 || h.KDF != "argon2id" {
+		return file, fmt.Errorf("unsupported %s version %d (%s)", kind, h.Version, h.KDF)
+	}
+	if h.Time == 0 || h.Time > maxKDFTime || h.Memory == 0 || h.Memory > maxKDFMemory || h.Threads == 0 || h.Threads > maxKDFThreads {
+		return file, fmt.Errorf("%s file %s has out of range key parameters", kind, path)
+	}
+	return file, nil
+}
+
+// encrypts plain with a new nonce, the header is authenticated with the data
+func sealVaultFile(header vaultHeader, key []byte, plain []byte) (vaultFile, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return vaultFile{}, err
+	}
+	file := vaultFile{Header: header, Nonce: make([]byte, aead.NonceSize())}
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return vaultFile{}, err
+	}
+	additionalData, _ := json.Marshal(header)
+	file.Data = aead.Seal(nil, file.Nonce, plain, additionalData)
+	return file, nil
+}
+
+// decrypts the data, fails with a wrong key or a file changed on disk
+func (f vaultFile) open(key []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	additionalData, _ := json.Marshal(f.Header)
+	return aead.Open(nil, f.Nonce, f.Data, additionalData)
+}
+
+// writes the file aside and renames it, so a failed write never loses the
+// previous one
+func (f vaultFile) write(path string) error {
+	content, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// opens the vault at path with the passphrase, an empty vault is returned
+// when the file does not exist yet
+func openVault(path string, passphrase string) (*vault, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("vault passphrase cannot be blank")
+	}
+	file, err := readVaultFile(path, "vault")
+	if os.IsNotExist(err) {
+		v := &vault{path: path, Profiles: make(map[string]Credentials)}
+		if v.header, err = newVaultHeader(3); err != nil {
+			return nil, err
+		}
+		v.key = v.header.deriveKey(passphrase)
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	v := &vault{path: path, header: file.Header}
+	v.key = v.header.deriveKey(passphrase)
+	plain, err := file.open(v.key)
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase or damaged vault")
+	}
+	if err := json.Unmarshal(plain, v); err != nil {
+		return nil, fmt.Errorf("invalid vault content: %v", err)
+	}
+	if v.Profiles == nil {
+		v.Profiles = make(map[string]Credentials)
+	}
+	slog.Info("Vault unlocked", "path", path, "profiles", len(v.Profiles))
+	return v, nil
+}
+
+// encrypts the profiles with a new nonce and replaces the vault file
+func (v *vault) save() error {
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	file, err := sealVaultFile(v.header, v.key, plain)
+	if err != nil {
+		return err
+	}
+	if err := file.write(v.path); err != nil {
+		return err
+	}
+	slog.Info("Vault saved", "path", v.path, "profiles", len(v.Profiles))
+	return nil
+}
+
+// returns the credentials of a profile
+func (v *vault) profile(name string) (Credentials, error) {
+	creds, found := v.Profiles[name]
+	if !found {
+		return Credentials{}, fmt.Errorf("profile '%s' not found in vault", name)
+	}
+	return creds, nil
+}
+
+// returns the profile names, sorted
+func (v *vault) profileNames() []string {
+	var names []string
+	for name := range v.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// unlocks the vault and returns the credentials of a profile, used by the UI
+func unlockProfile(passphrase string, name string) (Credentials, error) {
+	path, err := vaultPath()
+	if err != nil {
+		return Credentials{}, err
+	}
+	if !vaultExists(path) {
+		return Credentials{}, fmt.Errorf("no vault yet, type your credentials and press ctrl+s to remember them")
+	}
+	v, err := openVault(path, passphrase)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return v.profile(name)
+}
+
+// stores the credentials of a profile, creating the vault when needed
+func rememberProfile(passphrase string, name string, creds Credentials) error {
+	path, err := vaultPath()
+	if err != nil {
+		return err
+	}
+	v, err := openVault(path, passphrase)
+	if err != nil {
+		return err
+	}
+	v.Profiles[name] = creds
+	return v.save()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestVault stores two profiles in a temporary vault and reads them back
+func TestVault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+
+	work := Credentials{TimenetPassword: "t1", KimaiID: "jdoe", KimaiPassword: "k1"}
+	v, err := openVault(path, "correct horse")
+	if err != nil {
+		t.Fatalf("create vault: %v", err)
+	}
+	v.Profiles["default"] = work
+	v.Profiles["other"] = Credentials{KimaiID: "jane"}
+	if err := v.save(); err != nil {
+		t.Fatalf("save vault: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read vault file: %v", err)
+	}
+	if strings.Contains(string(content), "jdoe") {
+		t.Errorf("credentials stored in clear")
+	}
+
+	reopened, err := openVault(path, "correct horse")
+	if err != nil {
+		t.Fatalf("reopen vault: %v", err)
+	}
+	got, err := reopened.profile("default")
+	if err != nil {
+		t.Fatalf("read profile: %v", err)
+	}
+	if got != work {
+		t.Errorf("got credentials %+v, expected %+v", got, work)
+	}
+	if names := strings.Join(reopened.profileNames(), ","); names != "default,other" {
+		t.Errorf("got profiles %s, expected default,other", names)
+	}
+	if _, err := reopened.profile("missing"); err == nil {
+		t.Errorf("reading a missing profile did not fail")
+	}
+	if _, err := openVault(path, "wrong horse"); err == nil {
+		t.Errorf("opening with a wrong passphrase did not fail")
+	}
+
+	// flip one byte of the encrypted data
+	var file vaultFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		t.Fatalf("decode vault file: %v", err)
+	}
+	file.Data[0] ^= 0xff
+	tampered, _ := json.Marshal(file)
+	if err := os.WriteFile(path, tampered, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openVault(path, "correct horse"); err == nil {
+		t.Errorf("opening tampered data did not fail")
+	}
+
+	// a header asking for more memory than allowed is rejected before any
+	// key is derived
+	file.Data[0] ^= 0xff
+	file.Header.Memory = 64 * 1024 * 1024
+	tampered, _ = json.Marshal(file)
+	if err := os.WriteFile(path, tampered, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openVault(path, "correct horse"); err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("got %v, expected out of range key parameters", err)
+	}
+}